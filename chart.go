@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/text"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+	"gonum.org/v1/plot/vg/vgimg"
+	"gonum.org/v1/plot/vg/vgsvg"
+)
+
+// Графики задержки и потерь строятся по истории измерений библиотекой
+// gonum.org/v1/plot: PNG рисует vg/vgimg, SVG — vg/vgsvg, поэтому оба файла
+// выглядят одинаково. CGO не нужен, графики строятся и в режиме без GUI.
+
+// chartValue — значение линии графика; NaN — разрыв линии (нет ответов)
+type chartValue struct {
+	Time  time.Time
+	Value float64
+}
+
+// chartRange — минимум и максимум RTT в момент Time
+type chartRange struct {
+	Time      time.Time
+	Low, High float64
+}
+
+// chartLine — линия графика
+type chartLine struct {
+	Name   string
+	Color  color.NRGBA
+	Points []chartValue
+}
+
+// chartLoss — потери за период [Time, Time+Span]
+type chartLoss struct {
+	Time  time.Time
+	Span  time.Duration
+	Loss  float64 // %
+	Color color.NRGBA
+}
+
+// chart — график RTT по времени: полосы мин/макс, линии и отметки потерь.
+// Потери 100% закрашивают всю высоту графика, частичные — полосу внизу
+// высотой пропорционально потерям.
+type chart struct {
+	Title    string
+	From, To time.Time
+	Bands    [][]chartRange // Непрерывные участки полосы мин/макс
+	Lines    []chartLine
+	Loss     []chartLoss
+	Legend   bool // Подписи линий справа от графика
+}
+
+// Цвета графиков
+var (
+	chartBandColor   = color.NRGBA{70, 130, 220, 70}
+	chartAvgColor    = color.NRGBA{30, 90, 200, 255}
+	chartLossColor   = color.NRGBA{220, 50, 50, 170}
+	chartOutageColor = color.NRGBA{220, 50, 50, 45}
+	chartGridColor   = color.NRGBA{220, 220, 220, 255}
+	chartAxisColor   = color.NRGBA{120, 120, 120, 255}
+	chartTextColor   = color.NRGBA{40, 40, 40, 255}
+
+	// chartPalette — цвета линий хостов на общем графике
+	chartPalette = []color.NRGBA{
+		{31, 119, 180, 255}, {255, 127, 14, 255}, {44, 160, 44, 255}, {214, 39, 40, 255},
+		{148, 103, 189, 255}, {140, 86, 75, 255}, {227, 119, 194, 255}, {127, 127, 127, 255},
+		{188, 189, 34, 255}, {23, 190, 207, 255},
+	}
+)
+
+// hostChart строит график хоста по записям истории: полоса мин/макс RTT,
+// линия среднего и отметки потерь
+func hostChart(title string, samples []historySample, from, to time.Time) chart {
+	ch := chart{Title: title, From: from, To: to}
+	avg := chartLine{Name: "среднее", Color: chartAvgColor}
+	var band []chartRange
+	for i, s := range samples {
+		if s.Received > 0 {
+			band = append(band, chartRange{s.Time, s.MinRTT, s.MaxRTT})
+			avg.Points = append(avg.Points, chartValue{s.Time, s.AvgRTT})
+		} else {
+			if len(band) > 0 {
+				ch.Bands = append(ch.Bands, band)
+				band = nil
+			}
+			avg.Points = append(avg.Points, chartValue{s.Time, math.NaN()})
+		}
+		if loss := s.Loss(); loss > 0 {
+			ch.Loss = appendLoss(ch.Loss, chartLoss{s.Time, sampleSpan(samples, i), loss, chartLossColor})
+		}
+	}
+	if len(band) > 0 {
+		ch.Bands = append(ch.Bands, band)
+	}
+	ch.Lines = []chartLine{avg}
+	return ch
+}
+
+// overviewChart строит общий график: среднее RTT всех хостов и их потери
+// цветом хоста. series — записи истории по хостам, names — подписи хостов.
+func overviewChart(names []string, series [][]historySample, from, to time.Time) chart {
+	ch := chart{Title: "Среднее RTT всех хостов", From: from, To: to, Legend: true}
+	for i, samples := range series {
+		c := chartPalette[i%len(chartPalette)]
+		line := chartLine{Name: names[i], Color: c}
+		for j, s := range samples {
+			value := math.NaN()
+			if s.Received > 0 {
+				value = s.AvgRTT
+			}
+			line.Points = append(line.Points, chartValue{s.Time, value})
+			if loss := s.Loss(); loss > 0 {
+				lossColor := c
+				lossColor.A = 150
+				ch.Loss = appendLoss(ch.Loss, chartLoss{s.Time, sampleSpan(samples, j), loss, lossColor})
+			}
+		}
+		ch.Lines = append(ch.Lines, line)
+	}
+	return ch
+}
+
+// appendLoss добавляет отметку потерь; подряд идущие отметки с одинаковыми
+// потерями объединяются, чтобы полупрозрачные полосы не накладывались
+func appendLoss(marks []chartLoss, l chartLoss) []chartLoss {
+	if n := len(marks); n > 0 {
+		last := &marks[n-1]
+		if last.Loss == l.Loss && last.Color == l.Color && last.Time.Add(last.Span).Equal(l.Time) {
+			last.Span += l.Span
+			return marks
+		}
+	}
+	return append(marks, l)
+}
+
+// sampleSpan возвращает длительность записи i: до следующей записи,
+// для последней — как у предыдущей
+func sampleSpan(samples []historySample, i int) time.Duration {
+	switch {
+	case i+1 < len(samples):
+		return samples[i+1].Time.Sub(samples[i].Time)
+	case i > 0:
+		return samples[i].Time.Sub(samples[i-1].Time)
+	}
+	return time.Minute
+}
+
+// chartFont — шрифт подписей графиков. Liberation Sans встроен в
+// gonum.org/v1/plot и содержит кириллицу.
+var chartFont = font.Font{Typeface: "Liberation", Variant: "Sans"}
+
+// Размеры подписей и отступы графиков, пикселей
+const (
+	chartMargin      = 8
+	chartTitleSize   = 14
+	chartLabelSize   = 11
+	chartLegendWidth = 260
+	chartLegendLine  = 18
+	chartLegendTop   = 40 // Отступ легенды сверху, на уровне области построения
+)
+
+// chartLength переводит пиксели в единицы vg при разрешении PNG; в SVG
+// график получается того же размера
+func chartLength(px float64) vg.Length {
+	return vg.Length(px) * vg.Inch / vgimg.DefaultDPI
+}
+
+// chartPNG рисует график размером width×height пикселей в PNG
+func chartPNG(ch chart, width, height int) ([]byte, error) {
+	c := vgimg.New(chartLength(float64(width)), chartLength(float64(height)))
+	if err := ch.draw(draw.New(c)); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := (vgimg.PngCanvas{Canvas: c}).WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// chartSVG рисует график размером width×height пикселей в SVG
+func chartSVG(ch chart, width, height int) ([]byte, error) {
+	return plotSVG(ch.draw, width, height)
+}
+
+// plotSVG рисует drawFn на холсте SVG размером width×height пикселей
+func plotSVG(drawFn func(draw.Canvas) error, width, height int) ([]byte, error) {
+	c := vgsvg.New(chartLength(float64(width)), chartLength(float64(height)))
+	if err := drawFn(draw.New(c)); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newChartPlot создаёт график с заголовком title и подписями шрифтом chartFont
+func newChartPlot(title string) *plot.Plot {
+	p := plot.New()
+	p.Title.Text = title
+	p.Title.TextStyle.Font = font.From(chartFont, chartLength(chartTitleSize))
+	p.Title.TextStyle.Color = chartTextColor
+	p.Title.Padding = chartLength(8)
+	for _, axis := range []*plot.Axis{&p.X, &p.Y} {
+		axis.Label.TextStyle.Font = font.From(chartFont, chartLength(chartLabelSize))
+		axis.Label.TextStyle.Color = chartTextColor
+		axis.Tick.Label.Font = font.From(chartFont, chartLength(chartLabelSize))
+		axis.Tick.Label.Color = chartTextColor
+		axis.Color = chartAxisColor
+		axis.Tick.Color = chartAxisColor
+	}
+	return p
+}
+
+// chartPadding возвращает холст c без полей по краям
+func chartPadding(c draw.Canvas) draw.Canvas {
+	m := chartLength(chartMargin)
+	return draw.Crop(c, m, -m, m, -m)
+}
+
+// chartRect возвращает прямоугольник [x0, x1]×[y0, y1] с заливкой fill
+func chartRect(x0, x1, y0, y1 float64, fill color.Color) *plotter.Polygon {
+	return &plotter.Polygon{
+		XYs:   []plotter.XYs{{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}},
+		Color: fill,
+	}
+}
+
+// timeAxisTicks возвращает деления оси времени периода [from, to];
+// значение деления — секунды от from
+func timeAxisTicks(from, to time.Time) plot.ConstantTicks {
+	step, layout := timeTicks(to.Sub(from))
+	var ticks plot.ConstantTicks
+	for t := from.Truncate(step); !t.After(to); t = t.Add(step) {
+		if t.Before(from) {
+			continue
+		}
+		ticks = append(ticks, plot.Tick{Value: t.Sub(from).Seconds(), Label: t.Format(layout)})
+	}
+	return ticks
+}
+
+// plot строит график библиотекой gonum/plot. Ось X — секунды от начала
+// периода, ось Y — RTT в мс.
+func (ch chart) plot() (*plot.Plot, error) {
+	from, to := ch.From, ch.To
+	if !to.After(from) {
+		from, to = from.Add(-time.Minute), from.Add(time.Minute)
+	}
+	x := func(t time.Time) float64 {
+		return t.Sub(from).Seconds()
+	}
+	maxY := niceCeil(ch.maxValue() * 1.1)
+
+	p := newChartPlot(ch.Title)
+	p.Y.Label.Text = "мс"
+	p.X.Tick.Marker = timeAxisTicks(from, to)
+	const yTicks = 5
+	var rttTicks plot.ConstantTicks
+	for i := 0; i <= yTicks; i++ {
+		v := maxY * float64(i) / yTicks
+		rttTicks = append(rttTicks, plot.Tick{Value: v, Label: formatTick(v)})
+	}
+	p.Y.Tick.Marker = rttTicks
+
+	grid := plotter.NewGrid()
+	grid.Vertical.Color = chartGridColor
+	grid.Horizontal.Color = chartGridColor
+	grid.Vertical.Dashes, grid.Horizontal.Dashes = nil, nil
+	p.Add(grid)
+
+	// Потери под линиями, чтобы не закрывать RTT
+	for _, l := range ch.Loss {
+		x0, x1 := x(l.Time), x(l.Time.Add(l.Span))
+		if l.Loss >= 100 {
+			outage := l.Color
+			outage.A = chartOutageColor.A
+			p.Add(chartRect(x0, x1, 0, maxY, outage))
+		}
+		p.Add(chartRect(x0, x1, 0, maxY*0.2*l.Loss/100, l.Color))
+	}
+
+	for _, band := range ch.Bands {
+		if len(band) == 1 {
+			// Одиночное измерение — вертикальный отрезок мин/макс
+			seg, err := plotter.NewLine(plotter.XYs{{X: x(band[0].Time), Y: band[0].Low}, {X: x(band[0].Time), Y: band[0].High}})
+			if err != nil {
+				return nil, err
+			}
+			seg.Color, seg.Width = chartBandColor, vg.Points(2)
+			p.Add(seg)
+			continue
+		}
+		ring := make(plotter.XYs, 0, 2*len(band))
+		for _, r := range band {
+			ring = append(ring, plotter.XY{X: x(r.Time), Y: r.High})
+		}
+		for i := len(band) - 1; i >= 0; i-- {
+			ring = append(ring, plotter.XY{X: x(band[i].Time), Y: band[i].Low})
+		}
+		p.Add(&plotter.Polygon{XYs: []plotter.XYs{ring}, Color: chartBandColor})
+	}
+
+	for _, line := range ch.Lines {
+		var pts plotter.XYs
+		flush := func() error {
+			switch {
+			case len(pts) == 1:
+				dot, err := plotter.NewScatter(pts)
+				if err != nil {
+					return err
+				}
+				dot.Color, dot.Radius, dot.Shape = line.Color, vg.Points(1.5), draw.CircleGlyph{}
+				p.Add(dot)
+			case len(pts) > 1:
+				l, err := plotter.NewLine(pts)
+				if err != nil {
+					return err
+				}
+				l.Color, l.Width = line.Color, vg.Points(1.5)
+				p.Add(l)
+			}
+			pts = nil
+			return nil
+		}
+		for _, v := range line.Points {
+			if math.IsNaN(v.Value) {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			pts = append(pts, plotter.XY{X: x(v.Time), Y: v.Value})
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !ch.hasData() {
+		labels, err := plotter.NewLabels(plotter.XYLabels{
+			XYs:    plotter.XYs{{X: x(to) / 2, Y: maxY / 2}},
+			Labels: []string{"Нет данных за сессию"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		labels.TextStyle[0].Font = font.From(chartFont, chartLength(chartLabelSize))
+		labels.TextStyle[0].Color = chartAxisColor
+		labels.TextStyle[0].XAlign = text.XCenter
+		p.Add(labels)
+	}
+
+	// Add расширяет оси по данным, поэтому границы задаются в конце:
+	// отметки потерь последней записи не выходят за период
+	p.X.Min, p.X.Max = 0, x(to)
+	p.Y.Min, p.Y.Max = 0, maxY
+	return p, nil
+}
+
+// draw рисует график на c; с легендой область построения сужается,
+// а подписи линий выводятся справа от неё
+func (ch chart) draw(c draw.Canvas) error {
+	p, err := ch.plot()
+	if err != nil {
+		return err
+	}
+	c.SetColor(color.White)
+	c.Fill(c.Rectangle.Path())
+	c = chartPadding(c)
+	if !ch.Legend {
+		p.Draw(c)
+		return nil
+	}
+
+	legendWidth := chartLength(chartLegendWidth)
+	p.Draw(draw.Crop(c, 0, -legendWidth, 0, 0))
+
+	legend := plot.NewLegend()
+	legend.Top, legend.Left = true, true
+	legend.TextStyle.Font = font.From(chartFont, chartLength(chartLabelSize))
+	legend.TextStyle.Color = chartTextColor
+	legend.ThumbnailWidth = chartLength(10)
+	// Строка легенды — chartLegendLine пикселей вместе с отступом
+	legend.Padding = chartLength(chartLegendLine) - legend.TextStyle.Height("Ж")
+	top := chartLength(chartLegendTop)
+	maxItems := int((c.Max.Y - c.Min.Y - top) / chartLength(chartLegendLine))
+	for i, line := range ch.Lines {
+		if i == maxItems-1 && len(ch.Lines) > maxItems {
+			legend.Add(fmt.Sprintf("… и ещё %d", len(ch.Lines)-i))
+			break
+		}
+		legend.Add(line.Name, chartRect(0, 0, 0, 0, line.Color))
+	}
+	area := c.Max.X - c.Min.X
+	legend.Draw(draw.Crop(c, area-legendWidth+chartLength(16), 0, 0, -top))
+	return nil
+}
+
+// maxValue возвращает наибольшее значение на графике; без данных — 1
+func (ch chart) maxValue() float64 {
+	maxY := 0.0
+	for _, band := range ch.Bands {
+		for _, r := range band {
+			maxY = math.Max(maxY, r.High)
+		}
+	}
+	for _, line := range ch.Lines {
+		for _, p := range line.Points {
+			if !math.IsNaN(p.Value) {
+				maxY = math.Max(maxY, p.Value)
+			}
+		}
+	}
+	if maxY <= 0 {
+		return 1
+	}
+	return maxY
+}
+
+// hasData сообщает, есть ли на графике измерения
+func (ch chart) hasData() bool {
+	if len(ch.Loss) > 0 {
+		return true
+	}
+	for _, line := range ch.Lines {
+		if len(line.Points) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// niceCeil округляет v вверх до 1, 2 или 5, умноженных на степень 10
+func niceCeil(v float64) float64 {
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+// formatTick форматирует подпись деления оси RTT
+func formatTick(v float64) string {
+	if v >= 10 || v == 0 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.2g", v)
+}
+
+// timeTicks выбирает шаг делений оси времени (не больше 8 делений) и формат подписей
+func timeTicks(span time.Duration) (time.Duration, string) {
+	steps := []time.Duration{
+		time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+		time.Hour, 2 * time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+	}
+	step := steps[len(steps)-1]
+	for _, s := range steps {
+		if span/s <= 8 {
+			step = s
+			break
+		}
+	}
+	for span/step > 8 {
+		step *= 2
+	}
+	if span > 24*time.Hour {
+		return step, "02.01 15:04"
+	}
+	return step, "15:04"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/image/font/sfnt"
+	"gonum.org/v1/plot/font"
+)
+
+// Подписи графиков на русском: в шрифте графиков должны быть все их символы
+func TestChartFontCyrillic(t *testing.T) {
+	face := font.DefaultCache.Lookup(chartFont, 12)
+	var buf sfnt.Buffer
+	for _, r := range "АБВГДЕЁЖЗИЙКЛМНОПРСТУФХЦЧШЩЪЫЬЭЮЯабвгдеёжзийклмнопрстуфхцчшщъыьэюя…—–№" {
+		if i, err := face.Face.GlyphIndex(&buf, r); err != nil || i == 0 {
+			t.Errorf("в шрифте нет символа %q", r)
+		}
+	}
+}
+
+// testOverviewChart возвращает общий график двух хостов за 30 минут
+// с потерей всех пакетов на 10-й минуте
+func testOverviewChart() chart {
+	from := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+	var samples []historySample
+	for i := 0; i < 30; i++ {
+		s := historySample{Time: from.Add(time.Duration(i) * time.Minute), Cycles: 1, Sent: 4, Received: 4, MinRTT: 10, AvgRTT: 15, MaxRTT: 25}
+		if i == 10 {
+			s.Received = 0
+		}
+		samples = append(samples, s)
+	}
+	return overviewChart([]string{"Яндекс", "8.8.8.8"}, [][]historySample{samples, samples}, from, from.Add(30*time.Minute))
+}
+
+func TestChartPNG(t *testing.T) {
+	data, err := chartPNG(testOverviewChart(), 800, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 800 || b.Dy() != 300 {
+		t.Errorf("размер %v, ожидался 800×300", b)
+	}
+}
+
+func TestChartSVG(t *testing.T) {
+	data, err := chartSVG(testOverviewChart(), 800, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(data, new(struct{})); err != nil {
+		t.Fatalf("SVG не разбирается: %v", err)
+	}
+	// Заголовок, легенда и подписи оси времени выводятся текстом
+	for _, want := range []string{"Среднее RTT всех хостов", "Яндекс", "8.8.8.8", ">18:10<", "Liberation Sans"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("в SVG нет %q", want)
+		}
+	}
+
+	empty, err := chartSVG(hostChart("Пусто", nil, time.Now(), time.Now()), 800, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(empty), "Нет данных за сессию") {
+		t.Error("на пустом графике нет подписи об отсутствии данных")
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// defaultConfigPath — файл конфигурации, который читается при запуске без -config
+const defaultConfigPath = "pingstats.toml"
+
+// Config описывает файл конфигурации pingstats.toml
+type Config struct {
+	Interval      int           `toml:"interval"` // Интервал между циклами пинга, сек
+	LogDir        string        `toml:"log_dir"`
+	LogFormats    []string      `toml:"log_formats"`              // Форматы логов результатов: text, jsonl, csv
+	Discover      bool          `toml:"discover"`                 // Добавлять IP устройства, шлюз и первые хопы
+	MetricsListen string        `toml:"metrics_listen,omitempty"` // Адрес сервера метрик Prometheus
+	Interfaces    []string      `toml:"interfaces,omitempty"`     // Интерфейсы, с которых проверяются хосты
+	Probe         ProbeConfig   `toml:"probe"`
+	History       HistoryConfig `toml:"history"`
+	Groups        []HostGroup   `toml:"groups"`
+}
+
+// HistoryConfig задаёт хранение истории измерений в history.db
+type HistoryConfig struct {
+	Enabled         bool          `toml:"enabled"`
+	RawRetention    time.Duration `toml:"raw_retention"`    // Срок хранения результатов каждого цикла
+	MinuteRetention time.Duration `toml:"minute_retention"` // Срок хранения агрегатов за минуту
+	HourRetention   time.Duration `toml:"hour_retention"`   // Срок хранения агрегатов за час
+}
+
+// ProbeConfig задаёт параметры серии эхо-запросов
+type ProbeConfig struct {
+	Type           string        `toml:"type"` // icmp, exec или tcp
+	Count          int           `toml:"count"`
+	Size           int           `toml:"size"`
+	Timeout        time.Duration `toml:"timeout"`
+	PacketInterval time.Duration `toml:"packet_interval"`
+	Window         int           `toml:"window"`
+	TCPPort        int           `toml:"tcp_port"` // Порт для tcp:// без порта и probe = "tcp"
+	DNSName        string        `toml:"dns_name"` // Запрос для dns://, dns+tcp:// и dot:// без имени
+	DNSType        string        `toml:"dns_type"`
+	CertWarnDays   int           `toml:"cert_warn_days"` // Порог предупреждения об истечении сертификата
+	DualStack      bool          `toml:"dual_stack"`     // Отдельные строки IPv4 и IPv6 для двухстековых имён
+}
+
+// HostGroup — именованная группа хостов
+type HostGroup struct {
+	Name  string      `toml:"name"`
+	Hosts []HostEntry `toml:"hosts"`
+}
+
+// HostEntry — хост из конфигурации
+type HostEntry struct {
+	Address string `toml:"address"`
+	Label   string `toml:"label,omitempty"`
+	Probe   string `toml:"probe,omitempty"` // Переопределяет probe.type для адреса без схемы
+
+	// Проверки ответа для http(s)://
+	ExpectStatus int    `toml:"expect_status,omitempty"` // Ожидаемый код; по умолчанию любой ниже 400
+	BodyContains string `toml:"body_contains,omitempty"`
+	BodyRegex    string `toml:"body_regex,omitempty"`
+}
+
+// httpCheck возвращает проверки ответа для хоста
+func (h HostEntry) httpCheck() (HTTPCheck, error) {
+	check := HTTPCheck{ExpectStatus: h.ExpectStatus, BodyContains: h.BodyContains}
+	if h.BodyRegex != "" {
+		re, err := regexp.Compile(h.BodyRegex)
+		if err != nil {
+			return HTTPCheck{}, fmt.Errorf("неверное регулярное выражение body_regex: %v", err)
+		}
+		check.BodyRegex = re
+	}
+	return check, nil
+}
+
+// hostInfo — сведения о хосте из конфигурации, нужные при пинге и выводе
+type hostInfo struct {
+	Label string
+	Group string
+	Probe string
+	HTTP  HTTPCheck
+}
+
+// extraGroupName — группа, в которую GUI сохраняет введённые вручную хосты
+const extraGroupName = "Дополнительные"
+
+// defaultConfig возвращает настройки, с которыми программа работала без файла
+func defaultConfig() *Config {
+	return &Config{
+		Interval:   10,
+		LogDir:     "stats_and_graphs",
+		LogFormats: []string{logFormatText},
+		Discover:   true,
+		Probe: ProbeConfig{
+			Type:           pingBackendICMP,
+			Count:          4,
+			Size:           56,
+			Timeout:        time.Second,
+			PacketInterval: time.Second,
+			Window:         100,
+			TCPPort:        443,
+			DNSName:        "ya.ru",
+			DNSType:        "A",
+			CertWarnDays:   14,
+			DualStack:      true,
+		},
+		History: HistoryConfig{
+			Enabled:         true,
+			RawRetention:    48 * time.Hour,
+			MinuteRetention: 30 * 24 * time.Hour,
+			HourRetention:   365 * 24 * time.Hour,
+		},
+	}
+}
+
+// loadConfig читает файл конфигурации. Отсутствующие в файле параметры
+// берутся из defaultConfig. Если файла нет и required == false,
+// возвращаются настройки по умолчанию.
+func loadConfig(path string, required bool) (*Config, error) {
+	cfg := defaultConfig()
+	if _, err := toml.DecodeFile(path, cfg); err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("ошибка чтения конфигурации %s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("ошибка в конфигурации %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// validate проверяет параметры на те же ограничения, что и поля GUI
+func (c *Config) validate() error {
+	if c.Interval < 5 || c.Interval > 3600 {
+		return fmt.Errorf("interval должен быть от 5 до 3600 секунд")
+	}
+	if err := validateProbeType(c.Probe.Type); err != nil {
+		return fmt.Errorf("probe.type: %v", err)
+	}
+	if c.Probe.Count < 1 || c.Probe.Count > 100 {
+		return fmt.Errorf("probe.count должен быть от 1 до 100")
+	}
+	if c.Probe.Size < 0 || c.Probe.Size > 65000 {
+		return fmt.Errorf("probe.size должен быть от 0 до 65000 байт")
+	}
+	if c.Probe.Timeout < 100*time.Millisecond || c.Probe.Timeout > 10*time.Second {
+		return fmt.Errorf("probe.timeout должен быть от 100ms до 10s")
+	}
+	if c.Probe.PacketInterval < 200*time.Millisecond || c.Probe.PacketInterval > 10*time.Second {
+		return fmt.Errorf("probe.packet_interval должен быть от 200ms до 10s")
+	}
+	if c.Probe.Window < 10 || c.Probe.Window > 100000 {
+		return fmt.Errorf("probe.window должен быть от 10 до 100000")
+	}
+	if c.Probe.TCPPort < 1 || c.Probe.TCPPort > 65535 {
+		return fmt.Errorf("probe.tcp_port должен быть от 1 до 65535")
+	}
+	if strings.TrimSpace(c.Probe.DNSName) == "" {
+		return fmt.Errorf("probe.dns_name не может быть пустым")
+	}
+	if _, err := parseDNSType(c.Probe.DNSType); err != nil {
+		return fmt.Errorf("probe.dns_type: %v", err)
+	}
+	if c.Probe.CertWarnDays < 0 || c.Probe.CertWarnDays > 365 {
+		return fmt.Errorf("probe.cert_warn_days должен быть от 0 до 365")
+	}
+	for _, format := range c.LogFormats {
+		if err := validateLogFormat(format); err != nil {
+			return fmt.Errorf("log_formats: %v", err)
+		}
+	}
+	if c.History.RawRetention < time.Hour {
+		return fmt.Errorf("history.raw_retention должен быть не меньше 1h")
+	}
+	if c.History.MinuteRetention < c.History.RawRetention {
+		return fmt.Errorf("history.minute_retention не может быть меньше history.raw_retention")
+	}
+	if c.History.HourRetention < c.History.MinuteRetention {
+		return fmt.Errorf("history.hour_retention не может быть меньше history.minute_retention")
+	}
+	for _, iface := range c.Interfaces {
+		if strings.TrimSpace(iface) == "" {
+			return fmt.Errorf("interfaces: пустое имя интерфейса")
+		}
+	}
+	for _, g := range c.Groups {
+		for _, h := range g.Hosts {
+			if strings.TrimSpace(h.Address) == "" {
+				return fmt.Errorf("в группе %q есть хост без address", g.Name)
+			}
+			target, err := parseTarget(h.Address, pingBackendICMP)
+			if err != nil {
+				return err
+			}
+			if h.ExpectStatus != 0 || h.BodyContains != "" || h.BodyRegex != "" {
+				if target.URL == "" {
+					return fmt.Errorf("хост %s: expect_status, body_contains и body_regex задаются только для http(s)://", h.Address)
+				}
+				if h.ExpectStatus != 0 && (h.ExpectStatus < 100 || h.ExpectStatus > 599) {
+					return fmt.Errorf("хост %s: expect_status должен быть от 100 до 599", h.Address)
+				}
+				if _, err := h.httpCheck(); err != nil {
+					return fmt.Errorf("хост %s: %v", h.Address, err)
+				}
+			}
+			if h.Probe != "" {
+				if err := validateProbeType(h.Probe); err != nil {
+					return fmt.Errorf("хост %s: %v", h.Address, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateProbeType проверяет название способа пинга
+func validateProbeType(probe string) error {
+	switch probe {
+	case pingBackendICMP, pingBackendExec, pingBackendTCP:
+		return nil
+	}
+	return fmt.Errorf("неизвестный способ пинга %q (допустимо: %s, %s, %s)", probe, pingBackendICMP, pingBackendExec, pingBackendTCP)
+}
+
+// monitorOptions возвращает параметры сбора статистики из конфигурации
+func (c *Config) monitorOptions() MonitorOptions {
+	return MonitorOptions{
+		Interval: time.Duration(c.Interval) * time.Second,
+		Probe: ProbeOptions{
+			Backend:  c.Probe.Type,
+			Count:    c.Probe.Count,
+			Size:     c.Probe.Size,
+			Interval: c.Probe.PacketInterval,
+			Timeout:  c.Probe.Timeout,
+			TCPPort:  c.Probe.TCPPort,
+			DNSName:  c.Probe.DNSName,
+			DNSType:  c.Probe.DNSType,
+
+			CertWarnDays: c.Probe.CertWarnDays,
+			DualStack:    c.Probe.DualStack,
+			Interfaces:   c.Interfaces,
+		},
+		Window:     c.Probe.Window,
+		LogDir:     resolveLogDir(c.LogDir),
+		LogFormats: c.LogFormats,
+	}
+}
+
+// historyRetention возвращает сроки хранения истории из конфигурации
+func (c *Config) historyRetention() historyRetention {
+	return historyRetention{
+		Raw:    c.History.RawRetention,
+		Minute: c.History.MinuteRetention,
+		Hour:   c.History.HourRetention,
+	}
+}
+
+// hostInfo возвращает сведения о хостах из конфигурации, ключ — адрес хоста
+func (c *Config) hostInfo() map[string]hostInfo {
+	info := make(map[string]hostInfo)
+	for _, g := range c.Groups {
+		for _, h := range g.Hosts {
+			// Ошибки проверок отсеиваются в validate
+			check, _ := h.httpCheck()
+			info[strings.TrimSpace(h.Address)] = hostInfo{Label: h.Label, Group: g.Name, Probe: h.Probe, HTTP: check}
+		}
+	}
+	return info
+}
+
+// configHosts возвращает адреса всех хостов из конфигурации
+func (c *Config) configHosts() []string {
+	var hosts []string
+	for _, g := range c.Groups {
+		for _, h := range g.Hosts {
+			hosts = append(hosts, strings.TrimSpace(h.Address))
+		}
+	}
+	return hosts
+}
+
+// groupHosts возвращает адреса хостов группы name
+func (c *Config) groupHosts(name string) []string {
+	var hosts []string
+	for _, g := range c.Groups {
+		if g.Name != name {
+			continue
+		}
+		for _, h := range g.Hosts {
+			hosts = append(hosts, strings.TrimSpace(h.Address))
+		}
+	}
+	return hosts
+}
+
+// saveConfig записывает конфигурацию в файл
+func saveConfig(path string, cfg *Config) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии файла конфигурации: %v", err)
+	}
+	defer file.Close()
+
+	if err := toml.NewEncoder(file).Encode(cfg); err != nil {
+		return fmt.Errorf("ошибка при записи конфигурации: %v", err)
+	}
+	return nil
+}
+
+// withExtraHosts возвращает копию конфигурации, в которой группа
+// extraGroupName содержит указанные хосты, кроме уже описанных в других группах
+func (c *Config) withExtraHosts(extra []string) *Config {
+	cfg := *c
+	cfg.Groups = nil
+	known := make(map[string]bool)
+	for _, g := range c.Groups {
+		if g.Name == extraGroupName {
+			continue
+		}
+		cfg.Groups = append(cfg.Groups, g)
+		for _, h := range g.Hosts {
+			known[strings.TrimSpace(h.Address)] = true
+		}
+	}
+
+	group := HostGroup{Name: extraGroupName}
+	for _, host := range extra {
+		host = strings.TrimSpace(host)
+		if host == "" || known[host] {
+			continue
+		}
+		known[host] = true
+		group.Hosts = append(group.Hosts, HostEntry{Address: host})
+	}
+	if len(group.Hosts) > 0 {
+		cfg.Groups = append(cfg.Groups, group)
+	}
+	return &cfg
+}
+
+// uniqueHosts убирает пустые и повторяющиеся хосты, сохраняя порядок
+func uniqueHosts(hosts []string) []string {
+	seen := make(map[string]bool, len(hosts))
+	result := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		host = strings.TrimSpace(host)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		result = append(result, host)
+	}
+	return result
+}
+
+// resolveLogDir возвращает каталог для логов, пустой dir — каталог по умолчанию
+func resolveLogDir(dir string) string {
+	if dir == "" {
+		dir = "stats_and_graphs"
+	}
+	if runtime.GOOS == "windows" && !filepath.IsAbs(dir) {
+		dir = filepath.Join(".", dir)
+	}
+	return dir
+}
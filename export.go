@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportCSVHeader — столбцы выгрузки CSV для таблиц. Порядок не меняется,
+// новые столбцы добавляются в конец. RTT и джиттер в мс, потери в процентах.
+var exportCSVHeader = []string{"time", "host", "label", "group", "sent", "received", "loss_pct", "min_rtt_ms", "avg_rtt_ms", "max_rtt_ms", "jitter_ms"}
+
+// writeStatsCSV выгружает таблицу статистики в CSV: строка на хост с
+// показателями последнего цикла, в порядке snapshot
+func writeStatsCSV(w io.Writer, snapshot []PingStats) error {
+	cw := csv.NewWriter(w)
+	cw.Write(exportCSVHeader)
+	for i := range snapshot {
+		s := &snapshot[i]
+		cw.Write(exportCSVRow(s.LastUpdate, s.Host, hostInfo{Label: s.Label, Group: s.Group}, s.Sent, s.Received, s.PacketLoss, s.MinRTT, s.AvgRTT, s.MaxRTT, s.Jitter))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("ошибка при записи CSV: %v", err)
+	}
+	return nil
+}
+
+// writeHistoryCSV выгружает в CSV историю всех хостов за период [from, to]
+// с разрешением res: строки упорядочены по хосту, затем по времени.
+// info возвращает подпись и группу хоста.
+func writeHistoryCSV(w io.Writer, history *historyStore, res historyResolution, from, to time.Time, info func(host string) hostInfo) error {
+	hosts, err := history.Hosts(res)
+	if err != nil {
+		return err
+	}
+	sort.Strings(hosts)
+
+	cw := csv.NewWriter(w)
+	cw.Write(exportCSVHeader)
+	for _, host := range hosts {
+		samples, err := history.Range(host, res, from, to)
+		if err != nil {
+			return err
+		}
+		hi := info(host)
+		for _, s := range samples {
+			cw.Write(exportCSVRow(s.Time, host, hi, s.Sent, s.Received, s.Loss(), s.MinRTT, s.AvgRTT, s.MaxRTT, s.Jitter))
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("ошибка при записи CSV: %v", err)
+	}
+	return nil
+}
+
+// exportCSVRow возвращает строку выгрузки в порядке exportCSVHeader
+func exportCSVRow(t time.Time, host string, info hostInfo, sent, received int, loss, minRTT, avgRTT, maxRTT, jitter float64) []string {
+	return []string{
+		t.Format(csvTimeFormat),
+		host,
+		info.Label,
+		info.Group,
+		strconv.Itoa(sent),
+		strconv.Itoa(received),
+		csvFloat(loss),
+		csvFloat(minRTT),
+		csvFloat(avgRTT),
+		csvFloat(maxRTT),
+		csvFloat(jitter),
+	}
+}
+
+// parseExportTime разбирает границу периода выгрузки:
+//
+//	"24h"              — столько времени назад
+//	"2026-05-01"       — начало суток
+//	"2026-05-01 18:30" — время в часовом поясе системы
+//	RFC 3339           — "2026-05-01T18:30:00+03:00"
+func parseExportTime(text string, now time.Time) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if d, err := time.ParseDuration(text); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, text, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("время %q нужно указать как 24h, 2006-01-02, \"2006-01-02 15:04\" или в RFC 3339", text)
+}
+
+// parseHistoryResolution разбирает разрешение выгрузки; пустое — выбрать
+// по началу периода
+func parseHistoryResolution(text string) (historyResolution, error) {
+	switch res := historyResolution(text); res {
+	case "", historyRaw, historyMinute, historyHour:
+		return res, nil
+	}
+	return "", fmt.Errorf("%q не поддерживается (допустимо: %s, %s, %s)", text, historyRaw, historyMinute, historyHour)
+}
+
+// exportHistory выгружает историю из базы path за период [from, to] в CSV-файл
+// out ("-" — стандартный вывод). Пустое разрешение выбирается по началу периода.
+// База открывается только для чтения; пока её держит работающий экземпляр
+// программы, выгрузка делается через exportHandler.
+func exportHistory(path string, retention historyRetention, out string, res historyResolution, from, to time.Time, info func(host string) hostInfo) error {
+	history, err := openHistoryReadOnly(path, retention)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	if res == "" {
+		res = history.Resolution(from, time.Now())
+	}
+
+	if out == "-" {
+		return writeHistoryCSV(os.Stdout, history, res, from, to, info)
+	}
+	file, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла %s: %v", out, err)
+	}
+	if err := writeHistoryCSV(file, history, res, from, to, info); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("ошибка при записи файла %s: %v", out, err)
+	}
+	return nil
+}
+
+// exportHandler выгружает историю монитора m в CSV. Параметры запроса from, to
+// и resolution — как у флагов -from, -to и -resolution; по умолчанию
+// выгружаются последние сутки.
+func exportHandler(m *Monitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		history := m.History()
+		if history == nil {
+			http.Error(w, "история измерений не ведётся", http.StatusNotFound)
+			return
+		}
+		query := r.URL.Query()
+		now := time.Now()
+		from, err := parseExportTime(cmp.Or(query.Get("from"), "24h"), now)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("неверное начало периода: %v", err), http.StatusBadRequest)
+			return
+		}
+		to := now
+		if text := query.Get("to"); text != "" {
+			if to, err = parseExportTime(text, now); err != nil {
+				http.Error(w, fmt.Sprintf("неверный конец периода: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		res, err := parseHistoryResolution(query.Get("resolution"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("неверное разрешение: %v", err), http.StatusBadRequest)
+			return
+		}
+		if res == "" {
+			res = history.Resolution(from, now)
+		}
+
+		// CSV формируется целиком, чтобы ошибка чтения истории вернулась кодом ответа
+		var buf bytes.Buffer
+		if err := writeHistoryCSV(&buf, history, res, from, to, m.hostInfo); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="pingstats.csv"`)
+		w.Write(buf.Bytes())
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var exportTestRetention = historyRetention{Raw: 24 * time.Hour, Minute: 7 * 24 * time.Hour, Hour: 365 * 24 * time.Hour}
+
+// newExportTestHistory создаёт базу истории с двумя циклами по хосту за
+// последний час
+func newExportTestHistory(t *testing.T) (string, *historyStore) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), historyFileName)
+	history, err := openHistory(path, exportTestRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, host := range []string{"8.8.8.8", "1.1.1.1"} {
+		for cycle := 0; cycle < 2; cycle++ {
+			sample := historySample{
+				Time: start.Add(time.Duration(i*2+cycle) * time.Minute), Cycles: 1, Sent: 4, Received: 3,
+				MinRTT: 10, AvgRTT: 15, MaxRTT: 20, Jitter: 2, Failures: map[string]int{failTimeout: 1},
+			}
+			if err := history.Add(host, sample); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return path, history
+}
+
+// readExportCSV разбирает выгрузку и проверяет заголовок и порядок хостов
+func readExportCSV(t *testing.T, data string) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || strings.Join(records[0], ",") != strings.Join(exportCSVHeader, ",") {
+		t.Fatalf("выгрузка:\n%s", data)
+	}
+	if records[1][1] != "1.1.1.1" || records[4][1] != "8.8.8.8" || records[1][4] != "4" || records[1][6] != "25.000" {
+		t.Errorf("строки выгрузки:\n%s", data)
+	}
+	return records
+}
+
+func TestParseHistoryResolution(t *testing.T) {
+	for _, text := range []string{"", "raw", "1m", "1h"} {
+		if res, err := parseHistoryResolution(text); err != nil || string(res) != text {
+			t.Errorf("%q: %q, %v", text, res, err)
+		}
+	}
+	if _, err := parseHistoryResolution("5m"); err == nil {
+		t.Error("5m принято")
+	}
+}
+
+func TestExportHistoryReadOnly(t *testing.T) {
+	path, history := newExportTestHistory(t)
+	out := filepath.Join(t.TempDir(), "report.csv")
+	from, to := time.Now().Add(-2*time.Hour), time.Now()
+	noInfo := func(string) hostInfo { return hostInfo{} }
+
+	// Пока база открыта на запись, читатель получает подсказку про /export.csv
+	err := exportHistory(path, exportTestRetention, out, "", from, to, noInfo)
+	if err == nil || !strings.Contains(err.Error(), "/export.csv") {
+		t.Errorf("выгрузка из занятой базы: %v", err)
+	}
+	if err := history.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Два читателя открывают базу одновременно, файл базы не меняется
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := openHistoryReadOnly(path, exportTestRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := exportHistory(path, exportTestRetention, out, historyRaw, from, to, noInfo); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readExportCSV(t, string(data))
+	if after, err := os.Stat(path); err != nil || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("база изменена при выгрузке: %v", err)
+	}
+
+	// Отсутствующая база не создаётся
+	missing := filepath.Join(t.TempDir(), historyFileName)
+	if err := exportHistory(missing, exportTestRetention, out, "", from, to, noInfo); err == nil || !strings.Contains(err.Error(), "не найдена") {
+		t.Errorf("выгрузка из отсутствующей базы: %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("создана пустая база: %v", err)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	_, history := newExportTestHistory(t)
+	defer history.Close()
+	m := NewMonitor(MonitorOptions{Interval: time.Hour})
+	m.SetHostInfo(map[string]hostInfo{"8.8.8.8": {Label: "Google", Group: "DNS"}})
+	handler := exportHandler(m)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export.csv"+query, nil))
+		return rec
+	}
+
+	if rec := get(""); rec.Code != http.StatusNotFound {
+		t.Errorf("без истории: код %d", rec.Code)
+	}
+	m.SetHistory(history)
+
+	rec := get("?from=2h&resolution=raw")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("код %d, тип %q: %s", rec.Code, rec.Header().Get("Content-Type"), rec.Body)
+	}
+	records := readExportCSV(t, rec.Body.String())
+	if records[4][2] != "Google" || records[4][3] != "DNS" {
+		t.Errorf("подпись и группа %q/%q", records[4][2], records[4][3])
+	}
+
+	// Период без записей — только заголовок
+	if rec := get("?from=48h&to=47h"); rec.Code != http.StatusOK || strings.Count(rec.Body.String(), "\n") != 1 {
+		t.Errorf("пустой период: код %d: %s", rec.Code, rec.Body)
+	}
+	for _, query := range []string{"?from=вчера", "?to=завтра", "?resolution=5m"} {
+		if rec := get(query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: код %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Графики сохраняются в подкаталог каталога логов
+const graphsDirName = "graphs"
+
+// Размеры графиков, пикселей
+const (
+	hostGraphWidth      = 900
+	hostGraphHeight     = 320
+	overviewGraphWidth  = 1100
+	overviewGraphHeight = 420
+)
+
+// graphSeries — записи хоста за сессию: из истории измерений или из памяти монитора
+type graphSeries struct {
+	Host    string
+	Name    string // Подпись хоста для графика
+	Samples []historySample
+}
+
+// sessionSeries возвращает записи истории хостов snapshot за период
+// [from, to] с самым подробным сохранившимся разрешением
+func sessionSeries(history *historyStore, snapshot []PingStats, from, to time.Time) ([]graphSeries, error) {
+	res := history.Resolution(from, to)
+	series := make([]graphSeries, 0, len(snapshot))
+	for i := range snapshot {
+		samples, err := history.Range(snapshot[i].Host, res, from, to)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, graphSeries{Host: snapshot[i].Host, Name: snapshot[i].DisplayName(), Samples: samples})
+	}
+	return series, nil
+}
+
+// sessionPointsLimit — сколько результатов циклов хоста монитор хранит
+// в памяти для графиков без истории. При превышении соседние записи
+// попарно объединяются, как в агрегатах истории, поэтому графики
+// по-прежнему охватывают всю сессию, но с меньшей подробностью.
+const sessionPointsLimit = 2000
+
+// appendSessionPoint добавляет результаты цикла к записям хоста в памяти
+func appendSessionPoint(points []historySample, sample historySample) []historySample {
+	points = append(points, sample)
+	if len(points) <= sessionPointsLimit {
+		return points
+	}
+	compacted := points[:0]
+	for i := 0; i < len(points); i += 2 {
+		p := points[i]
+		if i+1 < len(points) {
+			p.merge(points[i+1])
+		}
+		compacted = append(compacted, p)
+	}
+	return compacted
+}
+
+// memorySeries возвращает записи хостов snapshot из результатов циклов,
+// сохранённых монитором в памяти
+func memorySeries(points map[string][]historySample, snapshot []PingStats) []graphSeries {
+	series := make([]graphSeries, 0, len(snapshot))
+	for i := range snapshot {
+		series = append(series, graphSeries{
+			Host:    snapshot[i].Host,
+			Name:    snapshot[i].DisplayName(),
+			Samples: append([]historySample(nil), points[snapshot[i].Host]...),
+		})
+	}
+	return series
+}
+
+// writeGraphs сохраняет в logDir/graphs графики RTT и потерь каждого хоста
+// и общий график всех хостов в PNG и SVG
+func writeGraphs(logDir string, series []graphSeries, from, to time.Time) error {
+	if logDir == "" || len(series) == 0 {
+		return nil
+	}
+	dir := filepath.Join(logDir, graphsDirName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("ошибка при создании каталога для графиков: %v", err)
+	}
+
+	names := make([]string, len(series))
+	samples := make([][]historySample, len(series))
+	used := make(map[string]bool)
+	for i, s := range series {
+		names[i], samples[i] = s.Name, s.Samples
+		if err := writeChart(dir, graphFileName(s.Host, used), hostChart(s.Name, s.Samples, from, to), hostGraphWidth, hostGraphHeight); err != nil {
+			return err
+		}
+	}
+	return writeChart(dir, "overview", overviewChart(names, samples, from, to), overviewGraphWidth, overviewGraphHeight)
+}
+
+// writeChart сохраняет график в файлы name.png и name.svg каталога dir
+func writeChart(dir, name string, ch chart, width, height int) error {
+	data, err := chartPNG(ch, width, height)
+	if err != nil {
+		return fmt.Errorf("ошибка при построении графика %s: %v", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".png"), data, 0644); err != nil {
+		return fmt.Errorf("ошибка при записи графика %s: %v", name, err)
+	}
+	svg, err := chartSVG(ch, width, height)
+	if err != nil {
+		return fmt.Errorf("ошибка при построении графика %s: %v", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".svg"), svg, 0644); err != nil {
+		return fmt.Errorf("ошибка при записи графика %s: %v", name, err)
+	}
+	return nil
+}
+
+// graphFileName возвращает имя файла графика хоста без расширения: символы,
+// недопустимые в именах файлов, заменяются на "_". used — уже занятые имена.
+func graphFileName(host string, used map[string]bool) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, host)
+	name = "host_" + strings.Trim(name, "_")
+	unique := name
+	for i := 2; used[unique]; i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[unique] = true
+	return unique
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// При превышении лимита записи хоста попарно объединяются и охватывают
+// всю сессию
+func TestAppendSessionPoint(t *testing.T) {
+	start := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+	var points []historySample
+	for i := 0; i <= sessionPointsLimit; i++ {
+		sample := historySample{Time: start.Add(time.Duration(i) * time.Second), Cycles: 1, Sent: 4, Received: 4, MinRTT: 1, AvgRTT: 2, MaxRTT: 3}
+		if i == 1 {
+			sample.Received, sample.MinRTT, sample.AvgRTT, sample.MaxRTT = 0, 0, 0, 0
+		}
+		points = appendSessionPoint(points, sample)
+		if i < sessionPointsLimit && len(points) != i+1 {
+			t.Fatalf("после %d записей хранится %d", i+1, len(points))
+		}
+	}
+
+	if len(points) != (sessionPointsLimit+2)/2 {
+		t.Fatalf("после объединения %d записей", len(points))
+	}
+	sent, cycles := 0, 0
+	for _, p := range points {
+		sent += p.Sent
+		cycles += p.Cycles
+	}
+	if cycles != sessionPointsLimit+1 || sent != 4*(sessionPointsLimit+1) {
+		t.Errorf("после объединения %d циклов, %d пакетов", cycles, sent)
+	}
+	first, last := points[0], points[len(points)-1]
+	if !first.Time.Equal(start) || !last.Time.Equal(start.Add(sessionPointsLimit*time.Second)) {
+		t.Errorf("записи охватывают %v — %v", first.Time, last.Time)
+	}
+	if first.Cycles != 2 || first.Received != 4 || first.MinRTT != 1 || first.AvgRTT != 2 {
+		t.Errorf("первая объединённая запись: %+v", first)
+	}
+}
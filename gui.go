@@ -1,77 +1,72 @@
+//go:build !nogui
+
 package main
 
 import (
 	"fmt"
 	"image/color"
 	"log"
-	"os"
-	"os/exec"
-	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
-type PingStats struct {
-	Host       string
-	MinRTT     float64
-	MaxRTT     float64
-	AvgRTT     float64
-	PacketLoss float64
-	LastUpdate time.Time
-}
-
-var (
-	statsMap      = make(map[string]*PingStats)
-	statsMutex    sync.RWMutex
-	mainWindow    fyne.Window
-	interval      int      = 10
-	systemHosts   []string // Хосты, собранные при запуске
-	extraHosts    []string // Дополнительные хосты
-	stopTicker    chan bool
-	mtrRunning    bool
-	mtrStopChan   chan bool
-	mtrWindow     fyne.Window      // Окно для MTR
-	mtrTextWidget *widget.TextGrid // Виджет для вывода MTR
+// guiAvailable сообщает, собрана ли программа с GUI
+const guiAvailable = true
+
+// gui — окна и виджеты, с которыми работают обработчики кнопок. Создаётся
+// в createGUI, поля меняются только из потока GUI. Состояние трассировки
+// (запущена ли, как остановить) хранит Monitor.
+type gui struct {
+	m             *Monitor
+	mtrWindow     fyne.Window      // Окно для MTR, nil если закрыто
+	mtrText       *widget.TextGrid // Вывод MTR в окне
+	mtrStopButton *widget.Button   // Кнопка остановки трассировки в окне MTR
 	mtrEntry      *widget.Entry    // Поле ввода для MTR в главном окне
-	defaultHosts  = []string{
-		"8.8.8.8",    // Google DNS
-		"1.1.1.1",    // Cloudflare DNS
-		"77.88.8.8",  // Yandex DNS
-		"ya.ru",      // Yandex
-		"google.com", // Google
-		"github.com", // GitHub
-	}
-)
-
-func updateStatsTable(table *widget.Table) {
-	fyne.Do(func() {
-		table.Refresh()
-	})
 }
 
-func showStatistics() {
-	statsMutex.RLock()
-	defer statsMutex.RUnlock()
-
+func showStatistics(m *Monitor) {
 	statsWindow := fyne.CurrentApp().NewWindow("Статистика пинга")
 	statsWindow.Resize(fyne.NewSize(800, 600))
 
 	text := "Собранная статистика:\n\n"
-	for host, stats := range statsMap {
-		text += fmt.Sprintf("Хост: %s\n", host)
+	snapshot := m.Snapshot()
+	for i := range snapshot {
+		stats := &snapshot[i]
+		text += fmt.Sprintf("Хост: %s\n", stats.DisplayName())
+		text += "  Текущий цикл:\n"
 		text += fmt.Sprintf("  Минимальное RTT: %.2f мс\n", stats.MinRTT)
 		text += fmt.Sprintf("  Среднее RTT: %.2f мс\n", stats.AvgRTT)
 		text += fmt.Sprintf("  Максимальное RTT: %.2f мс\n", stats.MaxRTT)
 		text += fmt.Sprintf("  Потери пакетов: %.1f%%\n", stats.PacketLoss)
+		if len(stats.Failures) > 0 {
+			text += fmt.Sprintf("  Причины потерь: %s\n", formatFailures(stats.Failures))
+		}
+		text += fmt.Sprintf("  Перцентили RTT (p50/p90/p95/p99): %.2f/%.2f/%.2f/%.2f мс\n", stats.P50, stats.P90, stats.P95, stats.P99)
+		text += fmt.Sprintf("  Стандартное отклонение: %.2f мс\n", stats.StdDev)
+		text += fmt.Sprintf("  Джиттер (RFC 3550): %.2f мс\n", stats.Jitter)
+		text += fmt.Sprintf("  Измерений в окне: %d\n", len(stats.Samples))
+		if stats.HTTP != nil {
+			text += formatHTTPStats(stats.HTTP)
+		}
+		if stats.DNS != nil {
+			text += formatDNSStats(stats.DNS)
+		}
+		if stats.TLS != nil {
+			text += formatTLSStats(stats.TLS)
+		}
+		text += formatSessionStats(stats)
 		text += fmt.Sprintf("  Последнее обновление: %s\n\n", stats.LastUpdate.Format("15:04:05"))
 	}
 
@@ -83,205 +78,192 @@ func showStatistics() {
 	statsWindow.Show()
 }
 
-func runMTRAndUpdateWindow(host string) {
-	if mtrRunning {
-		return
-	}
+// showDNSComparison показывает DNS-серверы от лучшего к худшему и
+// обновляет таблицу после каждого цикла проверки
+func showDNSComparison(m *Monitor) {
+	dnsWindow := fyne.CurrentApp().NewWindow("Сравнение DNS-серверов")
+	dnsWindow.Resize(fyne.NewSize(1000, 400))
 
-	mtrRunning = true
-	mtrStopChan = make(chan bool)
+	textWidget := widget.NewTextGrid()
+	textWidget.SetText(formatDNSComparison(m.Snapshot()))
 
+	updates, unsubscribe := m.Subscribe()
 	go func() {
-		if runtime.GOOS == "windows" {
-			// Используем winMTR вместо tracert
-			maxHops := 30
-			timeout := 2 * time.Second
-			hops, err := winMTR(host, maxHops, timeout)
-			fyne.Do(func() {
-				if mtrTextWidget != nil {
-					if err != nil {
-						mtrTextWidget.SetText(fmt.Sprintf("Ошибка winMTR: %v\n", err))
-					} else {
-						mtrTextWidget.SetText(FormatWinMTRResult(hops))
-					}
-				}
-				mtrRunning = false
-			})
-			return
+		for snapshot := range updates {
+			text := formatDNSComparison(snapshot)
+			fyne.Do(func() { textWidget.SetText(text) })
 		}
-		if !checkCommandAvailable("mtr") {
-			fyne.Do(func() {
-				if mtrTextWidget != nil {
-					mtrTextWidget.SetText("Ошибка: mtr не найден в системе.\n")
-				}
-				mtrRunning = false
-			})
-			return
-		}
-		cmd := exec.Command("mtr", "-n", "-r", "-c", "1", host)
-		output, err := cmd.CombinedOutput()
+	}()
+	dnsWindow.SetOnClosed(unsubscribe)
+
+	dnsWindow.SetContent(container.NewScroll(textWidget))
+	dnsWindow.Show()
+}
+
+// runMTR запускает трассировку до host и выводит её в окно MTR
+func (g *gui) runMTR(host string) {
+	// Трассируем до нажатия "Остановить трассировку", обновляя окно
+	// после каждого раунда. Монитор сохраняет результаты в лог,
+	// в том числе частичные после остановки
+	err := g.m.StartMTR(host, 30, func(report string) {
+		fyne.Do(func() {
+			if g.mtrText != nil {
+				g.mtrText.SetText(report)
+			}
+		})
+	}, func(output string, err error) {
 		if err != nil {
-			fyne.Do(func() {
-				if mtrTextWidget != nil {
-					mtrTextWidget.SetText(fmt.Sprintf("Ошибка трассировки: %v\n", err))
-				}
-				mtrRunning = false
-			})
-			return
-		}
-		if err := ensureLogDir(); err != nil {
-			log.Printf("Ошибка при создании каталога для логов: %v", err)
+			log.Printf("Ошибка трассировки до %s: %v", host, err)
+			output = fmt.Sprintf("Ошибка трассировки: %v\n\n%s", err, output)
 		} else {
-			if err := updateMTRStats(host, string(output)); err != nil {
-				log.Printf("Ошибка при обновлении файла логов MTR: %v", err)
-			}
+			log.Printf("Трассировка до %s остановлена", host)
 		}
 		fyne.Do(func() {
-			if mtrTextWidget != nil {
-				mtrTextWidget.SetText(string(output))
+			if g.mtrText != nil {
+				g.mtrText.SetText(output)
+			}
+			if g.mtrStopButton != nil {
+				g.mtrStopButton.Disable()
 			}
-			mtrRunning = false
 		})
-	}()
+	})
+	if err != nil {
+		// Трассировка уже идёт
+		return
+	}
+	if g.mtrStopButton != nil {
+		g.mtrStopButton.Enable()
+	}
 }
 
-func showMTRStats() {
-	if mtrWindow != nil {
-		mtrWindow.Show()
-		mtrWindow.Canvas().Refresh(mtrWindow.Content())
+func (g *gui) showMTRStats() {
+	if g.mtrWindow != nil {
+		g.mtrWindow.Show()
+		g.mtrWindow.Canvas().Refresh(g.mtrWindow.Content())
 		return
 	}
-	mtrWindow = fyne.CurrentApp().NewWindow("Статистика MTR")
-	mtrWindow.Resize(fyne.NewSize(800, 600))
-	mtrTextWidget = widget.NewTextGrid()
-	scrollContainer := container.NewScroll(mtrTextWidget)
+	g.mtrWindow = fyne.CurrentApp().NewWindow("Статистика MTR")
+	g.mtrWindow.Resize(fyne.NewSize(800, 600))
+	g.mtrText = widget.NewTextGrid()
+	scrollContainer := container.NewScroll(g.mtrText)
 	hostEntry := widget.NewEntry()
 	hostEntry.SetPlaceHolder("Введите хост для трассировки")
-	if mtrEntry != nil {
-		hostEntry.SetText(mtrEntry.Text)
+	hostEntry.SetText(g.mtrEntry.Text)
+	g.mtrStopButton = widget.NewButton("Остановить трассировку", g.m.StopMTR)
+	if !g.m.MTRRunning() {
+		g.mtrStopButton.Disable()
 	}
-	var stopButton *widget.Button
-	stopButton = widget.NewButton("Остановить трассировку", func() {
-		if mtrRunning {
-			mtrStopChan <- true
-			mtrRunning = false
-			fyne.Do(func() {
-				stopButton.Disable()
-			})
-		}
-	})
-	stopButton.Disable()
 	startButton := widget.NewButton("Запустить трассировку", func() {
 		host := hostEntry.Text
 		if host == "" {
 			return
 		}
-		if mtrEntry != nil {
-			mtrEntry.SetText(host)
-		}
-		runMTRAndUpdateWindow(host)
-		stopButton.Enable()
+		g.mtrEntry.SetText(host)
+		g.runMTR(host)
 	})
 	controls := container.NewVBox(
 		hostEntry,
-		container.NewHBox(startButton, stopButton),
+		container.NewHBox(startButton, g.mtrStopButton),
 	)
 	content := container.NewBorder(controls, nil, nil, nil, scrollContainer)
-	mtrWindow.SetContent(content)
-	mtrWindow.SetOnClosed(func() {
-		mtrWindow = nil
-		mtrTextWidget = nil
+	g.mtrWindow.SetContent(content)
+	g.mtrWindow.SetOnClosed(func() {
+		// Трассировка без окна никому не видна: останавливаем её,
+		// монитор запишет частичные результаты
+		g.m.StopMTR()
+		g.mtrWindow = nil
+		g.mtrText = nil
+		g.mtrStopButton = nil
 	})
 	if runtime.GOOS == "windows" {
-		mtrTextWidget.SetText("Внимание: на Windows используется tracert, а не mtr!\n")
+		g.mtrText.SetText("Внимание: на Windows для трассировки нужны права администратора (raw ICMP-сокет)!\n")
 	}
-	mtrWindow.Show()
-	mtrWindow.Canvas().Refresh(mtrWindow.Content())
+	g.mtrWindow.Show()
+	g.mtrWindow.Canvas().Refresh(g.mtrWindow.Content())
 }
 
-func createGUI(initialHosts []string) {
+func createGUI(m *Monitor, cfg *Config, cfgPath string) {
 	myApp := app.NewWithID("pingstats1nogui")
-	mainWindow = myApp.NewWindow("Ping Statistics")
+	mainWindow := myApp.NewWindow("Ping Statistics")
 	mainWindow.Resize(fyne.NewSize(1200, 800))
 
-	// Сохраняем системные хосты
-	systemHosts = initialHosts
+	// Хосты, собранные при запуске; к ним добавляются введённые в поле
+	systemHosts := m.Hosts()
+	opts := m.Options()
 
 	// Создаем темную тему
 	myApp.Settings().SetTheme(&customTheme{})
 
-	// Создаем таблицу для статистики
-	statsTable := widget.NewTable(
-		func() (int, int) {
-			return len(statsMap) + 1, 5 // +1 для заголовка
-		},
-		func() fyne.CanvasObject {
-			label := widget.NewLabel("")
-			label.TextStyle = fyne.TextStyle{Monospace: true}
-			return container.NewPadded(label)
-		},
-		func(i widget.TableCellID, o fyne.CanvasObject) {
-			container := o.(*fyne.Container)
-			label := container.Objects[0].(*widget.Label)
-
-			if i.Row == 0 {
-				// Заголовки
-				headers := []string{"Хост", "Мин. RTT", "Макс. RTT", "Ср. RTT", "Потери"}
-				label.SetText(headers[i.Col])
-				label.TextStyle = fyne.TextStyle{Bold: true}
-			} else {
-				statsMutex.RLock()
-				defer statsMutex.RUnlock()
-
-				// Данные
-				hosts := make([]string, 0, len(statsMap))
-				for h := range statsMap {
-					hosts = append(hosts, h)
-				}
-				if i.Row-1 < len(hosts) {
-					stats := statsMap[hosts[i.Row-1]]
-					switch i.Col {
-					case 0:
-						label.SetText(fmt.Sprintf("%-20s", stats.Host))
-					case 1:
-						label.SetText(fmt.Sprintf("%10.2f ms", stats.MinRTT))
-					case 2:
-						label.SetText(fmt.Sprintf("%10.2f ms", stats.MaxRTT))
-					case 3:
-						label.SetText(fmt.Sprintf("%10.2f ms", stats.AvgRTT))
-					case 4:
-						label.SetText(fmt.Sprintf("%8.1f%%", stats.PacketLoss))
-					}
-				}
-			}
-		},
-	)
-
-	// Устанавливаем фиксированную ширину колонок
-	statsTable.SetColumnWidth(0, 200) // Хост
-	statsTable.SetColumnWidth(1, 120) // Мин. RTT
-	statsTable.SetColumnWidth(2, 120) // Макс. RTT
-	statsTable.SetColumnWidth(3, 120) // Ср. RTT
-	statsTable.SetColumnWidth(4, 100) // Потери
+	// Создаем таблицу для статистики, сортировка сохраняется между запусками.
+	// Таблица обновляется после каждого цикла пинга.
+	statsTable := newStatsTable(myApp.Preferences(), m.Snapshot())
+	updates, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	go func() {
+		for snapshot := range updates {
+			fyne.Do(func() { statsTable.update(snapshot) })
+		}
+	}()
 
 	// Создаем элементы управления
-	intervalEntry := widget.NewEntry()
-	intervalEntry.SetText("10")
-	intervalEntry.Validator = func(s string) error {
-		val := 0
-		_, err := fmt.Sscanf(s, "%d", &val)
-		if err != nil || val < 5 || val > 3600 {
-			return fmt.Errorf("интервал должен быть от 5 до 3600 секунд")
-		}
-		return nil
+	intervalEntry := newIntEntry(int(opts.Interval/time.Second), 5, 3600, "интервал должен быть от 5 до 3600 секунд")
+
+	durationEntry := widget.NewEntry()
+	durationEntry.SetPlaceHolder("Без ограничения; 30m, 2h30m или до ЧЧ:ММ")
+	durationEntry.Validator = func(s string) error {
+		_, err := parseSessionEnd(s, time.Now())
+		return err
 	}
+	remainingLabel := widget.NewLabel("Сбор статистики не запущен")
 
 	hostsEntry := widget.NewEntry()
 	hostsEntry.SetPlaceHolder("Введите дополнительные хосты через запятую")
+	hostsEntry.SetText(strings.Join(cfg.groupHosts(extraGroupName), ", "))
+
+	// Параметры эхо-запросов
+	backendLabels := map[string]string{
+		"ICMP (встроенный)":        pingBackendICMP,
+		"ping (системная утилита)": pingBackendExec,
+		"TCP connect": pingBackendTCP,
+	}
+	backendSelect := widget.NewSelect([]string{"ICMP (встроенный)", "ping (системная утилита)", "TCP connect"}, nil)
+	for label, backend := range backendLabels {
+		if backend == opts.Probe.Backend {
+			backendSelect.SetSelected(label)
+		}
+	}
+	countEntry := newIntEntry(opts.Probe.Count, 1, 100, "число пакетов должно быть от 1 до 100")
+	sizeEntry := newIntEntry(opts.Probe.Size, 0, 65000, "размер пакета должен быть от 0 до 65000 байт")
+	timeoutEntry := newIntEntry(int(opts.Probe.Timeout.Milliseconds()), 100, 10000, "таймаут должен быть от 100 до 10000 мс")
+	probeIntervalEntry := newIntEntry(int(opts.Probe.Interval.Milliseconds()), 200, 10000, "интервал между пакетами должен быть от 200 до 10000 мс")
+	windowEntry := newIntEntry(opts.Window, 10, 100000, "окно выборки должно быть от 10 до 100000 измерений")
+	tcpPortEntry := newIntEntry(opts.Probe.TCPPort, 1, 65535, "порт должен быть от 1 до 65535")
+
+	// Интерфейсы, с которых проверяются хосты. Без выбора проверки идут
+	// через маршрут по умолчанию.
+	routes, _ := getDefaultRoutes()
+	ifaces, err := listInterfaces(routes)
+	if err != nil {
+		log.Printf("Предупреждение: %v", err)
+	}
+	ifaceNames := make(map[string]string)
+	var ifaceLabels []string
+	for _, iface := range ifaces {
+		ifaceNames[iface.String()] = iface.Name
+		ifaceLabels = append(ifaceLabels, iface.String())
+	}
+	ifaceGroup := widget.NewCheckGroup(ifaceLabels, nil)
+	ifaceGroup.Horizontal = true
+	for _, iface := range ifaces {
+		if slices.Contains(opts.Probe.Interfaces, iface.Name) {
+			ifaceGroup.Selected = append(ifaceGroup.Selected, iface.String())
+		}
+	}
 
 	// Добавляем отдельное поле для MTR
-	mtrEntry = widget.NewEntry()
+	mtrEntry := widget.NewEntry()
 	mtrEntry.SetPlaceHolder("Введите хост для MTR")
+	g := &gui{m: m, mtrEntry: mtrEntry}
 	mtrEntry.Validator = func(s string) error {
 		if s == "" {
 			return fmt.Errorf("хост не может быть пустым")
@@ -289,107 +271,104 @@ func createGUI(initialHosts []string) {
 		return nil
 	}
 
-	stopTicker = make(chan bool)
-
 	// Создаем кнопки
-	startButton := widget.NewButton("Запустить пинг", func() {
-		// Проверяем и создаем каталог для логов перед запуском
-		if err := ensureLogDir(); err != nil {
-			log.Printf("Ошибка при создании каталога для логов: %v", err)
+	var startButton, stopButton *widget.Button
+	startButton = widget.NewButton("Запустить пинг", func() {
+		end, err := parseSessionEnd(durationEntry.Text, time.Now())
+		if err != nil {
+			dialog.ShowError(err, mainWindow)
+			return
 		}
 
-		interval = 10
-		if val, err := fmt.Sscanf(intervalEntry.Text, "%d", &interval); err == nil && val > 0 {
-			// Валидация уже выполнена в Validator
+		// Параметры сбора и эхо-запросов
+		opts := m.Options()
+		opts.Interval = time.Duration(readIntEntry(intervalEntry, int(opts.Interval/time.Second))) * time.Second
+		opts.End = end
+		if backend, ok := backendLabels[backendSelect.Selected]; ok {
+			opts.Probe.Backend = backend
 		}
-
-		// Собираем все хосты: системные + дополнительные
-		allHosts := make([]string, len(systemHosts))
-		copy(allHosts, systemHosts)
-
-		// Добавляем дополнительные хосты, если они есть
-		if hostsEntry.Text != "" {
-			extraHosts = strings.Split(hostsEntry.Text, ",")
-			for _, host := range extraHosts {
-				host = strings.TrimSpace(host)
-				if host != "" {
-					allHosts = append(allHosts, host)
-				}
-			}
+		opts.Probe.Count = readIntEntry(countEntry, opts.Probe.Count)
+		opts.Probe.Size = readIntEntry(sizeEntry, opts.Probe.Size)
+		opts.Probe.Timeout = time.Duration(readIntEntry(timeoutEntry, int(opts.Probe.Timeout.Milliseconds()))) * time.Millisecond
+		opts.Probe.Interval = time.Duration(readIntEntry(probeIntervalEntry, int(opts.Probe.Interval.Milliseconds()))) * time.Millisecond
+		opts.Window = readIntEntry(windowEntry, opts.Window)
+		opts.Probe.TCPPort = readIntEntry(tcpPortEntry, opts.Probe.TCPPort)
+		opts.Probe.Interfaces = nil
+		for _, label := range ifaceGroup.Selected {
+			opts.Probe.Interfaces = append(opts.Probe.Interfaces, ifaceNames[label])
 		}
 
-		go func() {
-			ticker := time.NewTicker(time.Duration(interval) * time.Second)
-			defer ticker.Stop()
+		// Собираем все хосты: системные + дополнительные
+		allHosts := append(append([]string(nil), systemHosts...), strings.Split(hostsEntry.Text, ",")...)
 
-			// Создаем таймер для автоматической остановки
-			stopTimer := time.NewTimer(time.Duration(interval) * time.Second)
-			defer stopTimer.Stop()
+		// Монитор не даёт запустить второй сбор статистики параллельно первому
+		if m.Running() {
+			return
+		}
+		m.SetOptions(opts)
+		m.SetHosts(allHosts)
+		if err := m.Start(); err != nil {
+			dialog.ShowError(err, mainWindow)
+			return
+		}
+		done := m.Done()
 
-			// Сразу запускаем первый сбор статистики
-			startPingCollection(allHosts)
-			updateStatsTable(statsTable)
+		startButton.Disable()
+		stopButton.Enable()
 
+		// Показываем оставшееся время сессии
+		remainingLabel.SetText(formatRemaining(end, time.Now()))
+		go func() {
+			clock := time.NewTicker(time.Second)
+			defer clock.Stop()
 			for {
 				select {
-				case <-ticker.C:
-					startPingCollection(allHosts)
-					updateStatsTable(statsTable)
-				case <-stopTimer.C:
-					// По истечении интервала отправляем сигнал остановки
-					// Проверяем и создаем каталог для логов перед остановкой
-					if err := ensureLogDir(); err != nil {
-						log.Printf("Ошибка при создании каталога для логов: %v", err)
-					}
-
-					select {
-					case stopTicker <- true:
-						// Сигнал отправлен
-						// Обновляем содержимое каталога после остановки
-						if err := updateLogDir(); err != nil {
-							log.Printf("Ошибка при обновлении каталога логов: %v", err)
-						}
-					default:
-						// Канал не готов, значит пинг уже остановлен
-					}
-					return
-				case <-stopTicker:
+				case now := <-clock.C:
+					fyne.Do(func() { remainingLabel.SetText(formatRemaining(end, now)) })
+				case <-done:
+					fyne.Do(func() {
+						remainingLabel.SetText("Сбор статистики остановлен")
+						startButton.Enable()
+						stopButton.Disable()
+					})
 					return
 				}
 			}
 		}()
 	})
 
-	stopButton := widget.NewButton("Остановить", func() {
-		// Проверяем и создаем каталог для логов перед остановкой
-		if err := ensureLogDir(); err != nil {
-			log.Printf("Ошибка при создании каталога для логов: %v", err)
-		}
-
-		select {
-		case stopTicker <- true:
-			// Сигнал отправлен
-			// Создаем канал для синхронизации
-			done := make(chan bool)
-
-			// Обновляем логи в горутине
-			go func() {
-				if err := updateLogDir(); err != nil {
-					log.Printf("Ошибка при обновлении каталога логов: %v", err)
-				}
-				done <- true
-			}()
+	// Итоговая статистика сохраняется монитором после остановки
+	stopButton = widget.NewButton("Остановить", m.Stop)
+	stopButton.Disable()
 
-			// Ждем завершения обновления логов
-			<-done
-		default:
-			// Канал не готов, значит пинг не запущен
-		}
+	showStatsButton := widget.NewButton("Показать статистику", func() { showStatistics(m) })
+	showMTRStatsButton := widget.NewButton("Показать статистику MTR", g.showMTRStats)
+	showDNSButton := widget.NewButton("Сравнение DNS", func() { showDNSComparison(m) })
+
+	// Выгрузка таблицы в CSV в текущем порядке сортировки
+	exportButton := widget.NewButton("Экспорт в CSV", func() {
+		rows := append([]PingStats(nil), statsTable.rows...)
+		save := dialog.NewFileSave(func(w fyne.URIWriteCloser, err error) {
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+				return
+			}
+			if w == nil {
+				return
+			}
+			err = writeStatsCSV(w, rows)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				dialog.ShowError(err, mainWindow)
+			}
+		}, mainWindow)
+		save.SetFileName("pingstats_" + time.Now().Format("20060102_150405") + ".csv")
+		save.SetFilter(storage.NewExtensionFileFilter([]string{".csv"}))
+		save.Show()
 	})
 
-	showStatsButton := widget.NewButton("Показать статистику", showStatistics)
-	showMTRStatsButton := widget.NewButton("Показать статистику MTR", showMTRStats)
-
 	exitButton := widget.NewButton("Выход", func() {
 		mainWindow.Close()
 	})
@@ -399,10 +378,21 @@ func createGUI(initialHosts []string) {
 		if host == "" {
 			return
 		}
-		if mtrWindow == nil {
-			showMTRStats()
+		if g.mtrWindow == nil {
+			g.showMTRStats()
+		}
+		g.runMTR(host)
+	})
+
+	saveHostsButton := widget.NewButton("Сохранить хосты в конфиг", func() {
+		updated := cfg.withExtraHosts(strings.Split(hostsEntry.Text, ","))
+		if err := saveConfig(cfgPath, updated); err != nil {
+			dialog.ShowError(err, mainWindow)
+			return
 		}
-		runMTRAndUpdateWindow(host)
+		cfg = updated
+		m.SetHostInfo(cfg.hostInfo())
+		dialog.ShowInformation("Конфигурация", fmt.Sprintf("Список хостов сохранён в %s", cfgPath), mainWindow)
 	})
 
 	// Создаем подпись автора
@@ -411,15 +401,31 @@ func createGUI(initialHosts []string) {
 
 	// Создаем контейнер с элементами управления
 	controls := container.NewVBox(
-		widget.NewLabel("Интервал (сек):"),
-		intervalEntry,
+		container.NewGridWithColumns(2,
+			widget.NewLabel("Интервал (сек):"),
+			widget.NewLabel("Длительность сбора:"),
+			intervalEntry,
+			durationEntry,
+		),
 		widget.NewLabel("Хосты для пинга (через запятую):"),
-		hostsEntry,
-		container.NewHBox(startButton, stopButton),
+		container.NewBorder(nil, nil, nil, saveHostsButton, hostsEntry),
+		container.NewGridWithColumns(7,
+			widget.NewLabel("Способ пинга:"),
+			widget.NewLabel("Пакетов:"),
+			widget.NewLabel("Размер (байт):"),
+			widget.NewLabel("Таймаут (мс):"),
+			widget.NewLabel("Между пакетами (мс):"),
+			widget.NewLabel("Окно выборки:"),
+			widget.NewLabel("TCP-порт:"),
+			backendSelect, countEntry, sizeEntry, timeoutEntry, probeIntervalEntry, windowEntry, tcpPortEntry,
+		),
+		widget.NewLabel("Проверять с интерфейсов (без выбора — по маршруту по умолчанию):"),
+		ifaceGroup,
+		container.NewHBox(startButton, stopButton, remainingLabel),
 		widget.NewLabel("Хост для MTR:"),
 		mtrEntry,
 		container.NewHBox(mtrButton),
-		container.NewHBox(showStatsButton, showMTRStatsButton, exitButton),
+		container.NewHBox(showStatsButton, showMTRStatsButton, showDNSButton, exportButton, exitButton),
 	)
 
 	// Создаем контейнер с отступами
@@ -427,7 +433,7 @@ func createGUI(initialHosts []string) {
 		container.NewPadded(controls),
 		container.NewPadded(container.NewHBox(authorLabel)),
 		nil, nil,
-		container.NewPadded(statsTable),
+		container.NewPadded(statsTable.table),
 	)
 
 	mainWindow.SetContent(content)
@@ -446,6 +452,29 @@ func createGUI(initialHosts []string) {
 	mainWindow.ShowAndRun()
 }
 
+// newIntEntry создаёт поле ввода целого числа в диапазоне [min, max]
+func newIntEntry(value, min, max int, errText string) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(value))
+	entry.Validator = func(s string) error {
+		val, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || val < min || val > max {
+			return fmt.Errorf("%s", errText)
+		}
+		return nil
+	}
+	return entry
+}
+
+// readIntEntry возвращает значение поля или def, если оно не прошло проверку
+func readIntEntry(entry *widget.Entry, def int) int {
+	if entry.Validate() != nil {
+		return def
+	}
+	val, _ := strconv.Atoi(strings.TrimSpace(entry.Text))
+	return val
+}
+
 // Кастомная тема
 type customTheme struct{}
 
@@ -479,40 +508,3 @@ func (t *customTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
 func (t *customTheme) Size(name fyne.ThemeSizeName) float32 {
 	return theme.DefaultTheme().Size(name)
 }
-
-// Функция для обновления содержимого каталога логов
-func updateLogDir() error {
-	logDir := "stats_and_graphs"
-	if runtime.GOOS == "windows" {
-		logDir = filepath.Join(".", logDir)
-	}
-
-	// Создаем каталог, если его нет
-	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
-		return fmt.Errorf("ошибка при создании каталога для логов: %v", err)
-	}
-
-	// Создаем или обновляем файл с итоговой статистикой
-	statsFile := filepath.Join(logDir, "final_statistics.log")
-	file, err := os.OpenFile(statsFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("ошибка при открытии файла статистики: %v", err)
-	}
-	defer file.Close()
-
-	// Записываем текущую статистику
-	statsMutex.RLock()
-	defer statsMutex.RUnlock()
-
-	file.WriteString("Итоговая статистика пинга:\n\n")
-	for host, stats := range statsMap {
-		file.WriteString(fmt.Sprintf("Хост: %s\n", host))
-		file.WriteString(fmt.Sprintf("  Минимальное RTT: %.2f мс\n", stats.MinRTT))
-		file.WriteString(fmt.Sprintf("  Среднее RTT: %.2f мс\n", stats.AvgRTT))
-		file.WriteString(fmt.Sprintf("  Максимальное RTT: %.2f мс\n", stats.MaxRTT))
-		file.WriteString(fmt.Sprintf("  Потери пакетов: %.1f%%\n", stats.PacketLoss))
-		file.WriteString(fmt.Sprintf("  Последнее обновление: %s\n\n", stats.LastUpdate.Format("2006/01/02 15:04:05")))
-	}
-
-	return nil
-}
@@ -0,0 +1,12 @@
+//go:build nogui
+
+package main
+
+// guiAvailable сообщает, собрана ли программа с GUI.
+// Сборка с тегом nogui не зависит от Fyne и графических библиотек.
+const guiAvailable = false
+
+// createGUI в сборке без GUI не используется
+func createGUI(m *Monitor, cfg *Config, cfgPath string) {
+	panic("GUI недоступен: программа собрана с тегом nogui")
+}
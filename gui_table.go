@@ -0,0 +1,188 @@
+//go:build !nogui
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// statsColumn описывает колонку таблицы статистики
+type statsColumn struct {
+	Title string
+	Width float32
+	Text  func(s *PingStats) string
+	Value func(s *PingStats) float64 // Ключ сортировки; nil — сортировка по имени хоста
+	Warn  func(s *PingStats) bool    // Выделять ячейку как предупреждение; nil — не выделять
+}
+
+var statsColumns = []statsColumn{
+	{"Хост", 200, func(s *PingStats) string { return fmt.Sprintf("%-20s", s.DisplayName()) }, nil, nil},
+	{"Мин. RTT", 120, func(s *PingStats) string { return fmt.Sprintf("%10.2f ms", s.MinRTT) }, func(s *PingStats) float64 { return s.MinRTT }, nil},
+	{"Макс. RTT", 120, func(s *PingStats) string { return fmt.Sprintf("%10.2f ms", s.MaxRTT) }, func(s *PingStats) float64 { return s.MaxRTT }, nil},
+	{"Ср. RTT", 120, func(s *PingStats) string { return fmt.Sprintf("%10.2f ms", s.AvgRTT) }, func(s *PingStats) float64 { return s.AvgRTT }, nil},
+	{"Потери", 100, func(s *PingStats) string { return fmt.Sprintf("%8.1f%%", s.PacketLoss) }, func(s *PingStats) float64 { return s.PacketLoss }, nil},
+	{"p50", 100, func(s *PingStats) string { return fmt.Sprintf("%8.2f ms", s.P50) }, func(s *PingStats) float64 { return s.P50 }, nil},
+	{"p90", 100, func(s *PingStats) string { return fmt.Sprintf("%8.2f ms", s.P90) }, func(s *PingStats) float64 { return s.P90 }, nil},
+	{"p95", 100, func(s *PingStats) string { return fmt.Sprintf("%8.2f ms", s.P95) }, func(s *PingStats) float64 { return s.P95 }, nil},
+	{"p99", 100, func(s *PingStats) string { return fmt.Sprintf("%8.2f ms", s.P99) }, func(s *PingStats) float64 { return s.P99 }, nil},
+	{"СКО", 100, func(s *PingStats) string { return fmt.Sprintf("%8.2f ms", s.StdDev) }, func(s *PingStats) float64 { return s.StdDev }, nil},
+	{"Джиттер", 100, func(s *PingStats) string { return fmt.Sprintf("%8.2f ms", s.Jitter) }, func(s *PingStats) float64 { return s.Jitter }, nil},
+	{"Сертификат", 130, certText, certDaysLeft, func(s *PingStats) bool { return s.TLS != nil && (s.TLS.Warning || s.TLS.Error != "") }},
+}
+
+// certText показывает, сколько дней осталось до истечения сертификата
+func certText(s *PingStats) string {
+	switch {
+	case s.TLS == nil:
+		return ""
+	case len(s.TLS.Chain) == 0:
+		return "ошибка"
+	case s.TLS.Warning:
+		return fmt.Sprintf("⚠ %d дн.", s.TLS.DaysLeft)
+	}
+	return fmt.Sprintf("%d дн.", s.TLS.DaysLeft)
+}
+
+// certDaysLeft — ключ сортировки по сроку сертификата; хосты без TLS в конце
+func certDaysLeft(s *PingStats) float64 {
+	if s.TLS == nil || len(s.TLS.Chain) == 0 {
+		return math.MaxFloat64
+	}
+	return float64(s.TLS.DaysLeft)
+}
+
+// Ключи настроек Fyne, в которых хранится выбранная сортировка
+const (
+	prefStatsSortColumn = "statsSortColumn"
+	prefStatsSortDesc   = "statsSortDesc"
+)
+
+// statsTableModel хранит упорядоченный снимок статистики, который показывает
+// таблица. Строки меняются только в потоке UI, поэтому каждая строка таблицы
+// целиком относится к одному хосту.
+type statsTableModel struct {
+	table    *widget.Table
+	prefs    fyne.Preferences
+	rows     []PingStats
+	sortCol  int
+	sortDesc bool
+}
+
+// newStatsTable создаёт таблицу статистики с сортировкой по клику на заголовок
+// и показывает в ней snapshot. Сортировка восстанавливается из prefs и
+// сохраняется в них при изменении.
+func newStatsTable(prefs fyne.Preferences, snapshot []PingStats) *statsTableModel {
+	m := &statsTableModel{
+		prefs:    prefs,
+		sortCol:  prefs.IntWithFallback(prefStatsSortColumn, 0),
+		sortDesc: prefs.BoolWithFallback(prefStatsSortDesc, false),
+	}
+	if m.sortCol < 0 || m.sortCol >= len(statsColumns) {
+		m.sortCol = 0
+	}
+
+	m.table = widget.NewTable(
+		func() (int, int) {
+			return len(m.rows), len(statsColumns)
+		},
+		func() fyne.CanvasObject {
+			label := widget.NewLabel("")
+			label.TextStyle = fyne.TextStyle{Monospace: true}
+			return container.NewPadded(label)
+		},
+		func(i widget.TableCellID, o fyne.CanvasObject) {
+			label := o.(*fyne.Container).Objects[0].(*widget.Label)
+			label.Importance = widget.MediumImportance
+			if i.Row < len(m.rows) {
+				column := statsColumns[i.Col]
+				if column.Warn != nil && column.Warn(&m.rows[i.Row]) {
+					label.Importance = widget.WarningImportance
+				}
+				label.SetText(column.Text(&m.rows[i.Row]))
+			} else {
+				label.SetText("")
+			}
+		},
+	)
+	m.table.ShowHeaderRow = true
+	m.table.CreateHeader = func() fyne.CanvasObject {
+		return widget.NewButton("", nil)
+	}
+	m.table.UpdateHeader = func(id widget.TableCellID, o fyne.CanvasObject) {
+		button := o.(*widget.Button)
+		if id.Col < 0 {
+			return
+		}
+		col := id.Col
+		text := statsColumns[col].Title
+		if col == m.sortCol {
+			if m.sortDesc {
+				text += " ▼"
+			} else {
+				text += " ▲"
+			}
+		}
+		button.SetText(text)
+		button.OnTapped = func() { m.setSort(col) }
+	}
+	for col, c := range statsColumns {
+		m.table.SetColumnWidth(col, c.Width)
+	}
+
+	m.rows = snapshot
+	m.sortRows()
+	return m
+}
+
+// setSort сортирует по колонке col; повторный клик меняет направление
+func (m *statsTableModel) setSort(col int) {
+	if col == m.sortCol {
+		m.sortDesc = !m.sortDesc
+	} else {
+		m.sortCol = col
+		m.sortDesc = false
+	}
+	m.prefs.SetInt(prefStatsSortColumn, m.sortCol)
+	m.prefs.SetBool(prefStatsSortDesc, m.sortDesc)
+
+	// Снимок упорядочен по адресу; сортируем заново, чтобы порядок равных
+	// значений не зависел от предыдущей сортировки
+	sort.SliceStable(m.rows, func(i, j int) bool { return m.rows[i].Host < m.rows[j].Host })
+	m.sortRows()
+	m.table.Refresh()
+}
+
+// sortRows упорядочивает строки по выбранной колонке. Снимок уже отсортирован
+// по адресу, а сортировка устойчивая, поэтому хосты с равными значениями
+// не меняются местами между обновлениями.
+func (m *statsTableModel) sortRows() {
+	column := statsColumns[m.sortCol]
+	sort.SliceStable(m.rows, func(i, j int) bool {
+		a, b := &m.rows[i], &m.rows[j]
+		if column.Value == nil {
+			x, y := strings.ToLower(a.DisplayName()), strings.ToLower(b.DisplayName())
+			if m.sortDesc {
+				return x > y
+			}
+			return x < y
+		}
+		if m.sortDesc {
+			return column.Value(a) > column.Value(b)
+		}
+		return column.Value(a) < column.Value(b)
+	})
+}
+
+// update показывает свежий снимок статистики. Вызывается из потока UI.
+func (m *statsTableModel) update(snapshot []PingStats) {
+	m.rows = snapshot
+	m.sortRows()
+	m.table.Refresh()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runHeadless собирает статистику без GUI, пока не придёт SIGINT или SIGTERM
+// или не наступит время окончания из параметров монитора. При остановке
+// монитор сохраняет итоговую статистику.
+func runHeadless(m *Monitor) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	opts := m.Options()
+	log.Printf("Запуск без GUI: %d хостов, интервал %v. %s", len(m.Hosts()), opts.Interval, formatRemaining(opts.End, time.Now()))
+
+	if err := m.Start(); err != nil {
+		log.Printf("Ошибка запуска сбора статистики: %v", err)
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+		log.Println("Получен сигнал остановки, сохраняем итоговую статистику")
+		m.Stop()
+	case <-m.Done():
+		log.Println("Время сбора статистики истекло, итоговая статистика сохранена")
+	}
+	<-m.Done()
+}
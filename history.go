@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// historyFileName — файл базы истории в каталоге логов
+const historyFileName = "history.db"
+
+// Разрешения истории. Каждое измерение записывается как есть (historyRaw)
+// и сразу добавляется к агрегатам за минуту и за час, поэтому после удаления
+// старых исходных измерений по истечении срока хранения остаются агрегаты.
+type historyResolution string
+
+const (
+	historyRaw    historyResolution = "raw"
+	historyMinute historyResolution = "1m"
+	historyHour   historyResolution = "1h"
+)
+
+// historyRollups — разрешения агрегатов и длительность их периодов
+var historyRollups = []struct {
+	res    historyResolution
+	period time.Duration
+}{
+	{historyMinute, time.Minute},
+	{historyHour, time.Hour},
+}
+
+// historyRetention — сроки хранения истории по разрешениям
+type historyRetention struct {
+	Raw    time.Duration
+	Minute time.Duration
+	Hour   time.Duration
+}
+
+// historySample — результаты цикла пинга хоста или агрегат за период.
+// Time — время измерения или начало периода агрегата, RTT в мс.
+type historySample struct {
+	Time     time.Time      `json:"time"`
+	Cycles   int            `json:"cycles"` // Циклов пинга в записи; 1 для исходного измерения
+	Sent     int            `json:"sent"`
+	Received int            `json:"received"`
+	MinRTT   float64        `json:"min_rtt"`
+	AvgRTT   float64        `json:"avg_rtt"`
+	MaxRTT   float64        `json:"max_rtt"`
+	Jitter   float64        `json:"jitter"`
+	Failures map[string]int `json:"failures,omitempty"`
+}
+
+// historySampleFromStats возвращает запись истории по результатам цикла
+func historySampleFromStats(stats *PingStats) historySample {
+	return historySample{
+		Time:     stats.LastUpdate,
+		Cycles:   1,
+		Sent:     stats.Sent,
+		Received: stats.Received,
+		MinRTT:   stats.MinRTT,
+		AvgRTT:   stats.AvgRTT,
+		MaxRTT:   stats.MaxRTT,
+		Jitter:   stats.Jitter,
+		Failures: maps.Clone(stats.Failures),
+	}
+}
+
+// Loss возвращает процент потерь
+func (s historySample) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return float64(s.Sent-s.Received) / float64(s.Sent) * 100
+}
+
+// merge добавляет к агрегату запись o. Среднее RTT взвешивается по числу
+// ответов, джиттер — по числу циклов; минимум и максимум берутся только по
+// записям с ответами.
+func (s *historySample) merge(o historySample) {
+	if received := s.Received + o.Received; received > 0 {
+		s.AvgRTT = (s.AvgRTT*float64(s.Received) + o.AvgRTT*float64(o.Received)) / float64(received)
+	}
+	if o.Received > 0 {
+		if s.Received == 0 || o.MinRTT < s.MinRTT {
+			s.MinRTT = o.MinRTT
+		}
+		if s.Received == 0 || o.MaxRTT > s.MaxRTT {
+			s.MaxRTT = o.MaxRTT
+		}
+	}
+	if cycles := s.Cycles + o.Cycles; cycles > 0 {
+		s.Jitter = (s.Jitter*float64(s.Cycles) + o.Jitter*float64(o.Cycles)) / float64(cycles)
+	}
+	s.Cycles += o.Cycles
+	s.Sent += o.Sent
+	s.Received += o.Received
+	for reason, n := range o.Failures {
+		if s.Failures == nil {
+			s.Failures = make(map[string]int)
+		}
+		s.Failures[reason] += n
+	}
+}
+
+// historyStore — история измерений во встроенной базе bbolt (чистый Go, без CGO).
+// Для каждого разрешения заведён бакет, в нём — вложенный бакет на каждый хост
+// с записями historySample в JSON. Ключ записи — время в наносекундах Unix
+// (big-endian), поэтому записи упорядочены по времени и выбираются по диапазону.
+type historyStore struct {
+	db        *bbolt.DB
+	retention historyRetention
+}
+
+// openHistory открывает или создаёт базу истории path
+func openHistory(path string, retention historyRetention) (*historyStore, error) {
+	// Таймаут, чтобы второй экземпляр программы не ждал блокировку файла бесконечно
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		if errors.Is(err, bbolt.ErrTimeout) {
+			return nil, fmt.Errorf("база истории %s занята другим экземпляром программы", path)
+		}
+		return nil, fmt.Errorf("ошибка при открытии базы истории %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, res := range []historyResolution{historyRaw, historyMinute, historyHour} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(res)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка при создании бакетов истории: %v", err)
+	}
+	return &historyStore{db: db, retention: retention}, nil
+}
+
+// openHistoryReadOnly открывает существующую базу истории path только для
+// чтения. Такую блокировку могут держать несколько читателей сразу, но не
+// одновременно с работающим экземпляром программы, открывшим базу на запись:
+// у него история выгружается через /export.csv сервера метрик.
+func openHistoryReadOnly(path string, retention historyRetention) (*historyStore, error) {
+	db, err := bbolt.Open(path, 0644, &bbolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		switch {
+		case errors.Is(err, bbolt.ErrTimeout):
+			return nil, fmt.Errorf("база истории %s открыта работающим экземпляром программы; выгрузите историю через его сервер метрик: http://<metrics_listen>/export.csv", path)
+		case errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("история измерений %s не найдена", path)
+		}
+		return nil, fmt.Errorf("ошибка при открытии базы истории %s: %v", path, err)
+	}
+	return &historyStore{db: db, retention: retention}, nil
+}
+
+// Close закрывает базу истории
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+// historyKey возвращает ключ записи для времени t
+func historyKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// historyKeyTime возвращает время ключа записи
+func historyKeyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+// Add записывает результаты цикла пинга хоста и добавляет их к агрегатам
+// за минуту и за час
+func (h *historyStore) Add(host string, sample historySample) error {
+	err := h.db.Update(func(tx *bbolt.Tx) error {
+		raw, err := tx.Bucket([]byte(historyRaw)).CreateBucketIfNotExists([]byte(host))
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		if err := raw.Put(historyKey(sample.Time), data); err != nil {
+			return err
+		}
+
+		for _, rollup := range historyRollups {
+			b, err := tx.Bucket([]byte(rollup.res)).CreateBucketIfNotExists([]byte(host))
+			if err != nil {
+				return err
+			}
+			start := sample.Time.Truncate(rollup.period)
+			key := historyKey(start)
+			agg := historySample{Time: start}
+			if data := b.Get(key); data != nil {
+				if err := json.Unmarshal(data, &agg); err != nil {
+					return err
+				}
+			}
+			agg.merge(sample)
+			data, err := json.Marshal(agg)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при записи истории %s: %v", host, err)
+	}
+	return nil
+}
+
+// Range возвращает записи хоста с разрешением res за период [from, to],
+// упорядоченные по времени
+func (h *historyStore) Range(host string, res historyResolution, from, to time.Time) ([]historySample, error) {
+	var samples []historySample
+	err := h.db.View(func(tx *bbolt.Tx) error {
+		level := tx.Bucket([]byte(res))
+		if level == nil {
+			return fmt.Errorf("неизвестное разрешение %q", res)
+		}
+		b := level.Bucket([]byte(host))
+		if b == nil {
+			return nil
+		}
+		end := to.UnixNano()
+		c := b.Cursor()
+		for k, v := c.Seek(historyKey(from)); k != nil && historyKeyTime(k).UnixNano() <= end; k, v = c.Next() {
+			var s historySample
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			samples = append(samples, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении истории %s: %v", host, err)
+	}
+	return samples, nil
+}
+
+// Hosts возвращает хосты, по которым есть записи с разрешением res
+func (h *historyStore) Hosts(res historyResolution) ([]string, error) {
+	var hosts []string
+	err := h.db.View(func(tx *bbolt.Tx) error {
+		level := tx.Bucket([]byte(res))
+		if level == nil {
+			return fmt.Errorf("неизвестное разрешение %q", res)
+		}
+		return level.ForEachBucket(func(name []byte) error {
+			hosts = append(hosts, string(name))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении списка хостов истории: %v", err)
+	}
+	return hosts, nil
+}
+
+// Resolution выбирает самое подробное разрешение, записи которого за
+// период с from ещё не удалены по сроку хранения
+func (h *historyStore) Resolution(from, now time.Time) historyResolution {
+	switch age := now.Sub(from); {
+	case age <= h.retention.Raw:
+		return historyRaw
+	case age <= h.retention.Minute:
+		return historyMinute
+	}
+	return historyHour
+}
+
+// Prune удаляет записи старше срока хранения своего разрешения
+func (h *historyStore) Prune(now time.Time) error {
+	cutoffs := map[historyResolution]time.Time{
+		historyRaw:    now.Add(-h.retention.Raw),
+		historyMinute: now.Add(-h.retention.Minute),
+		historyHour:   now.Add(-h.retention.Hour),
+	}
+	err := h.db.Update(func(tx *bbolt.Tx) error {
+		for res, cutoff := range cutoffs {
+			level := tx.Bucket([]byte(res))
+			var hosts [][]byte
+			level.ForEachBucket(func(name []byte) error {
+				hosts = append(hosts, append([]byte(nil), name...))
+				return nil
+			})
+			for _, host := range hosts {
+				b := level.Bucket(host)
+				// Удаление во время обхода курсором пропускает записи,
+				// поэтому сначала собираем копии ключей
+				var old [][]byte
+				c := b.Cursor()
+				for k, _ := c.First(); k != nil && historyKeyTime(k).Before(cutoff); k, _ = c.Next() {
+					old = append(old, append([]byte(nil), k...))
+				}
+				for _, k := range old {
+					if err := b.Delete(k); err != nil {
+						return err
+					}
+				}
+				if k, _ := b.Cursor().First(); k == nil {
+					if err := level.DeleteBucket(host); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ошибка при удалении устаревшей истории: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// newHistoryTestStore открывает пустую базу истории во временном каталоге
+func newHistoryTestStore(t *testing.T, retention historyRetention) *historyStore {
+	t.Helper()
+	history, err := openHistory(filepath.Join(t.TempDir(), historyFileName), retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { history.Close() })
+	return history
+}
+
+func TestHistoryAddRollups(t *testing.T) {
+	history := newHistoryTestStore(t, exportTestRetention)
+	base := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+	samples := []historySample{
+		{Time: base.Add(10 * time.Second), Cycles: 1, Sent: 4, Received: 3, MinRTT: 8, AvgRTT: 10, MaxRTT: 12, Jitter: 1},
+		// Цикл без ответов не влияет на RTT агрегата
+		{Time: base.Add(30 * time.Second), Cycles: 1, Sent: 4, Received: 0, Jitter: 0, Failures: map[string]int{failTimeout: 4}},
+		{Time: base.Add(50 * time.Second), Cycles: 1, Sent: 4, Received: 1, MinRTT: 30, AvgRTT: 30, MaxRTT: 30, Jitter: 5, Failures: map[string]int{failTimeout: 3}},
+		{Time: base.Add(5 * time.Minute), Cycles: 1, Sent: 4, Received: 4, MinRTT: 5, AvgRTT: 20, MaxRTT: 40, Jitter: 3},
+	}
+	for _, s := range samples {
+		if err := history.Add("8.8.8.8", s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	from, to := base.Add(-time.Hour), base.Add(time.Hour)
+
+	raw, err := history.Range("8.8.8.8", historyRaw, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != len(samples) || !raw[1].Time.Equal(samples[1].Time) || raw[1].Failures[failTimeout] != 4 {
+		t.Errorf("исходные записи: %+v", raw)
+	}
+
+	minutes, err := history.Range("8.8.8.8", historyMinute, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(minutes) != 2 {
+		t.Fatalf("агрегатов за минуту %d: %+v", len(minutes), minutes)
+	}
+	want := historySample{
+		Cycles: 3, Sent: 12, Received: 4,
+		MinRTT: 8, AvgRTT: 15, MaxRTT: 30, Jitter: 2, // (10·3 + 30·1) / 4; (1 + 0 + 5) / 3
+		Failures: map[string]int{failTimeout: 7},
+	}
+	checkHistoryAggregate(t, "минута 18:00", minutes[0], base, want)
+	checkHistoryAggregate(t, "минута 18:05", minutes[1], base.Add(5*time.Minute), historySample{
+		Cycles: 1, Sent: 4, Received: 4, MinRTT: 5, AvgRTT: 20, MaxRTT: 40, Jitter: 3,
+	})
+
+	hours, err := history.Range("8.8.8.8", historyHour, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hours) != 1 {
+		t.Fatalf("агрегатов за час %d: %+v", len(hours), hours)
+	}
+	checkHistoryAggregate(t, "час 18:00", hours[0], base, historySample{
+		Cycles: 4, Sent: 16, Received: 8,
+		MinRTT: 5, AvgRTT: 17.5, MaxRTT: 40, Jitter: 2.25, // (10·3 + 30·1 + 20·4) / 8; (1 + 0 + 5 + 3) / 4
+		Failures: map[string]int{failTimeout: 7},
+	})
+	if hours[0].Loss() != 50 {
+		t.Errorf("потери за час %v%%", hours[0].Loss())
+	}
+}
+
+// Агрегат за период только из циклов без ответов: RTT остаются нулевыми
+func TestHistoryAddRollupsNoReplies(t *testing.T) {
+	history := newHistoryTestStore(t, exportTestRetention)
+	base := time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := history.Add("10.0.0.1", historySample{Time: base.Add(time.Duration(i) * time.Second), Cycles: 1, Sent: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	minutes, err := history.Range("10.0.0.1", historyMinute, base, base.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(minutes) != 1 {
+		t.Fatalf("агрегатов за минуту %d", len(minutes))
+	}
+	checkHistoryAggregate(t, "минута без ответов", minutes[0], base, historySample{Cycles: 2, Sent: 8})
+	if minutes[0].Loss() != 100 {
+		t.Errorf("потери %v%%", minutes[0].Loss())
+	}
+}
+
+func checkHistoryAggregate(t *testing.T, name string, got historySample, start time.Time, want historySample) {
+	t.Helper()
+	if !got.Time.Equal(start) {
+		t.Errorf("%s: начало периода %v, ожидалось %v", name, got.Time, start)
+	}
+	got.Time, want.Time = time.Time{}, time.Time{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s:\n получено %+v\nожидалось %+v", name, got, want)
+	}
+}
+
+func TestHistoryPrune(t *testing.T) {
+	retention := historyRetention{Raw: time.Hour, Minute: 24 * time.Hour, Hour: 30 * 24 * time.Hour}
+	history := newHistoryTestStore(t, retention)
+	now := time.Date(2026, 5, 10, 12, 30, 0, 0, time.UTC)
+	ages := []time.Duration{30 * time.Minute, 2 * time.Hour, 2 * 24 * time.Hour}
+	for _, age := range ages {
+		if err := history.Add("8.8.8.8", historySample{Time: now.Add(-age), Cycles: 1, Sent: 4, Received: 4}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// У хоста только записи старше всех сроков хранения
+	if err := history.Add("old.example", historySample{Time: now.Add(-40 * 24 * time.Hour), Cycles: 1, Sent: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := history.Prune(now); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		res  historyResolution
+		want []time.Time
+	}{
+		{historyRaw, []time.Time{now.Add(-ages[0])}},
+		{historyMinute, []time.Time{now.Add(-ages[1]).Truncate(time.Minute), now.Add(-ages[0]).Truncate(time.Minute)}},
+		{historyHour, []time.Time{now.Add(-ages[2]).Truncate(time.Hour), now.Add(-ages[1]).Truncate(time.Hour), now.Add(-ages[0]).Truncate(time.Hour)}},
+	} {
+		samples, err := history.Range("8.8.8.8", tt.res, now.Add(-365*24*time.Hour), now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []time.Time
+		for _, s := range samples {
+			got = append(got, s.Time.UTC())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: после удаления %v, ожидалось %v", tt.res, got, tt.want)
+		}
+
+		// Бакет хоста без записей удалён
+		hosts, err := history.Hosts(tt.res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(hosts)
+		if !reflect.DeepEqual(hosts, []string{"8.8.8.8"}) {
+			t.Errorf("%s: хосты %v", tt.res, hosts)
+		}
+	}
+}
+
+func TestHistoryResolution(t *testing.T) {
+	history := &historyStore{retention: historyRetention{Raw: time.Hour, Minute: 24 * time.Hour, Hour: 30 * 24 * time.Hour}}
+	now := time.Date(2026, 5, 10, 12, 30, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		age  time.Duration
+		want historyResolution
+	}{
+		{0, historyRaw},
+		{time.Hour, historyRaw},
+		{time.Hour + time.Second, historyMinute},
+		{24 * time.Hour, historyMinute},
+		{24*time.Hour + time.Second, historyHour},
+		{365 * 24 * time.Hour, historyHour},
+	} {
+		if got := history.Resolution(now.Add(-tt.age), now); got != tt.want {
+			t.Errorf("период %v: разрешение %s, ожидалось %s", tt.age, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// icmpMode описывает тип ICMP-сокета, который удалось открыть
+type icmpMode string
+
+const (
+	icmpModeDatagram  icmpMode = "udp4 (непривилегированный ICMP-сокет)"
+	icmpModeRaw       icmpMode = "ip4:icmp (raw-сокет)"
+	icmpModeDatagram6 icmpMode = "udp6 (непривилегированный ICMPv6-сокет)"
+	icmpModeRaw6      icmpMode = "ip6:ipv6-icmp (raw-сокет)"
+)
+
+// Номера протоколов для icmp.ParseMessage
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// icmpIDCounter различает raw-сокеты одного процесса
+var icmpIDCounter uint32
+
+// nextICMPID возвращает ID эхо-запросов для нового raw-сокета. Каждый
+// raw-сокет получает все ICMP-ответы системы, поэтому при параллельных
+// пингах и трассировках ответы разделяются по ID.
+func nextICMPID() int {
+	return int(uint32(os.Getpid())+atomic.AddUint32(&icmpIDCounter, 1)) & 0xffff
+}
+
+// icmpConn — ICMP- или ICMPv6-сокет для эхо-запросов с управляемым TTL
+type icmpConn struct {
+	conn net.PacketConn
+	p4   *ipv4.PacketConn // Для IPv4
+	p6   *ipv6.PacketConn // Для IPv6
+	mode icmpMode
+	id   int
+}
+
+// icmpReply содержит разобранный ответ на эхо-запрос
+type icmpReply struct {
+	Peer net.IP
+	Type icmp.Type // ipv4.ICMPType или ipv6.ICMPType
+	ID   int
+	Seq  int
+}
+
+// isEchoReply сообщает, что получен эхо-ответ
+func (r *icmpReply) isEchoReply() bool {
+	return r.Type == ipv4.ICMPTypeEchoReply || r.Type == ipv6.ICMPTypeEchoReply
+}
+
+// isTimeExceeded сообщает, что у запроса истёк TTL (Hop Limit в IPv6)
+func (r *icmpReply) isTimeExceeded() bool {
+	return r.Type == ipv4.ICMPTypeTimeExceeded || r.Type == ipv6.ICMPTypeTimeExceeded
+}
+
+// isUnreachable сообщает, что получено Destination Unreachable
+func (r *icmpReply) isUnreachable() bool {
+	return r.Type == ipv4.ICMPTypeDestinationUnreachable || r.Type == ipv6.ICMPTypeDestinationUnreachable
+}
+
+// listenICMPFrom открывает ICMP-сокет для семейства адреса dst. Если указан
+// интерфейс iface, сокет привязывается к его адресу того же семейства.
+func listenICMPFrom(dst net.IP, iface string) (*icmpConn, error) {
+	var src net.IP
+	if iface != "" {
+		var err error
+		if src, err = sourceIP(iface, ipFamily(dst)); err != nil {
+			return nil, err
+		}
+	}
+	if dst.To4() == nil {
+		return listenICMPv6(src, iface)
+	}
+	return listenICMP(src, iface)
+}
+
+// listenRawICMP открывает raw-сокет ICMP (network "ip4:icmp") или ICMPv6
+// ("ip6:ipv6-icmp") на адресе src; nil — на всех адресах
+func listenRawICMP(network string, src net.IP, iface string) (net.PacketConn, error) {
+	addr := "0.0.0.0"
+	if network == "ip6:ipv6-icmp" {
+		addr = "::"
+	}
+	if src != nil {
+		addr = src.String()
+	}
+	lc := net.ListenConfig{Control: bindToDevice(iface)}
+	return lc.ListenPacket(context.Background(), network, addr)
+}
+
+// isIPv6 сообщает, что сокет работает с ICMPv6
+func (c *icmpConn) isIPv6() bool {
+	return c.p6 != nil
+}
+
+// newRawICMPConn оборачивает raw-сокет ICMP
+func newRawICMPConn(raw net.PacketConn) *icmpConn {
+	return &icmpConn{
+		conn: raw,
+		p4:   ipv4.NewPacketConn(raw),
+		mode: icmpModeRaw,
+		id:   nextICMPID(),
+	}
+}
+
+// newRawICMPv6Conn оборачивает raw-сокет ICMPv6. На такой сокет приходят
+// все сообщения ICMPv6, включая Neighbor Discovery, поэтому ядру задаётся
+// фильтр; где фильтр не поддерживается, лишнее отсеет parseICMPv6Reply.
+func newRawICMPv6Conn(raw net.PacketConn) *icmpConn {
+	p6 := ipv6.NewPacketConn(raw)
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeEchoReply)
+	filter.Accept(ipv6.ICMPTypeTimeExceeded)
+	filter.Accept(ipv6.ICMPTypeDestinationUnreachable)
+	p6.SetICMPFilter(&filter)
+	return &icmpConn{
+		conn: raw,
+		p6:   p6,
+		mode: icmpModeRaw6,
+		id:   nextICMPID(),
+	}
+}
+
+func (c *icmpConn) Close() error {
+	return c.conn.Close()
+}
+
+// send отправляет эхо-запрос с указанными TTL и порядковым номером.
+// Зона dst нужна для link-local адресов IPv6, например шлюза fe80::1%eth0.
+func (c *icmpConn) send(dst *net.IPAddr, ttl, seq int, data []byte) error {
+	wmsg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{ID: c.id, Seq: seq, Data: data},
+	}
+	if c.isIPv6() {
+		// Контрольную сумму ICMPv6 считает ядро
+		wmsg.Type = ipv6.ICMPTypeEchoRequest
+		if err := c.p6.SetHopLimit(ttl); err != nil {
+			return fmt.Errorf("set hop limit: %v", err)
+		}
+	} else if err := c.p4.SetTTL(ttl); err != nil {
+		return fmt.Errorf("set ttl: %v", err)
+	}
+	wb, err := wmsg.Marshal(nil)
+	if err != nil {
+		return fmt.Errorf("marshal icmp: %v", err)
+	}
+
+	var addr net.Addr = dst
+	if c.mode == icmpModeDatagram || c.mode == icmpModeDatagram6 {
+		addr = &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}
+	}
+	_, err = c.conn.WriteTo(wb, addr)
+	return err
+}
+
+// recvSeq ждёт ответ на эхо-запрос с номером seq до наступления deadline.
+// Ответы на другие запросы и чужие ICMP-пакеты пропускаются.
+func (c *icmpConn) recvSeq(seq int, deadline time.Time) (*icmpReply, error) {
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for {
+		reply, err := c.recv()
+		if err != nil {
+			return nil, err
+		}
+		if reply != nil && reply.Seq == seq {
+			return reply, nil
+		}
+	}
+}
+
+// recv читает один ICMP-ответ. Возвращает nil без ошибки, если пакет
+// не относится к нашим запросам.
+func (c *icmpConn) recv() (*icmpReply, error) {
+	if c.mode == icmpModeDatagram || c.mode == icmpModeDatagram6 {
+		return c.recvDatagram()
+	}
+
+	rb := make([]byte, 1500)
+	n, peer, err := c.conn.ReadFrom(rb)
+	if err != nil {
+		return nil, err
+	}
+	parse := parseICMPReply
+	if c.isIPv6() {
+		parse = parseICMPv6Reply
+	}
+	reply, err := parse(rb[:n], addrIP(peer))
+	if err != nil || reply.ID != c.id {
+		return nil, nil
+	}
+	return reply, nil
+}
+
+// parseICMPReply разбирает ICMP-сообщение, полученное из raw-сокета.
+// Для Time Exceeded и Destination Unreachable ID и Seq берутся из
+// процитированного заголовка исходного эхо-запроса.
+func parseICMPReply(b []byte, peer net.IP) (*icmpReply, error) {
+	msg, err := icmp.ParseMessage(protocolICMP, b)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора ICMP: %v", err)
+	}
+
+	reply := &icmpReply{Peer: peer}
+	t, ok := msg.Type.(ipv4.ICMPType)
+	if !ok {
+		return nil, fmt.Errorf("неожиданный тип ICMP: %v", msg.Type)
+	}
+	reply.Type = t
+
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		// На raw-сокете видны и собственные эхо-запросы, их пропускаем
+		if t != ipv4.ICMPTypeEchoReply {
+			return nil, fmt.Errorf("не эхо-ответ: %v", msg.Type)
+		}
+		reply.ID, reply.Seq = body.ID, body.Seq
+	case *icmp.TimeExceeded:
+		reply.ID, reply.Seq, err = quotedEcho(body.Data)
+	case *icmp.DstUnreach:
+		reply.ID, reply.Seq, err = quotedEcho(body.Data)
+	default:
+		err = fmt.Errorf("неподдерживаемое ICMP-сообщение: %v", msg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// quotedEcho извлекает ID и Seq эхо-запроса из IP-заголовка и первых
+// 8 байт ICMP, которые маршрутизатор вкладывает в сообщение об ошибке
+func quotedEcho(data []byte) (int, int, error) {
+	if len(data) < ipv4.HeaderLen {
+		return 0, 0, fmt.Errorf("слишком короткое вложение ICMP")
+	}
+	hdrLen := int(data[0]&0x0f) * 4
+	if len(data) < hdrLen+8 {
+		return 0, 0, fmt.Errorf("слишком короткое вложение ICMP")
+	}
+	echo := data[hdrLen:]
+	if ipv4.ICMPType(echo[0]) != ipv4.ICMPTypeEcho {
+		return 0, 0, fmt.Errorf("вложение не является эхо-запросом")
+	}
+	return int(binary.BigEndian.Uint16(echo[4:6])), int(binary.BigEndian.Uint16(echo[6:8])), nil
+}
+
+// parseICMPv6Reply разбирает ICMPv6-сообщение, полученное из raw-сокета
+// (ядро передаёт его без IPv6-заголовка). Для Time Exceeded и Destination
+// Unreachable ID и Seq берутся из процитированного эхо-запроса.
+func parseICMPv6Reply(b []byte, peer net.IP) (*icmpReply, error) {
+	msg, err := icmp.ParseMessage(protocolICMPv6, b)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора ICMPv6: %v", err)
+	}
+
+	reply := &icmpReply{Peer: peer, Type: msg.Type}
+	switch body := msg.Body.(type) {
+	case *icmp.Echo:
+		if msg.Type != ipv6.ICMPTypeEchoReply {
+			return nil, fmt.Errorf("не эхо-ответ: %v", msg.Type)
+		}
+		reply.ID, reply.Seq = body.ID, body.Seq
+	case *icmp.TimeExceeded:
+		reply.ID, reply.Seq, err = quotedEchoV6(body.Data)
+	case *icmp.DstUnreach:
+		reply.ID, reply.Seq, err = quotedEchoV6(body.Data)
+	default:
+		err = fmt.Errorf("неподдерживаемое ICMPv6-сообщение: %v", msg.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// quotedEchoV6 извлекает ID и Seq эхо-запроса из процитированного пакета
+// IPv6. Эхо-запросы отправляются без заголовков расширения, поэтому
+// ICMPv6 начинается сразу за фиксированным заголовком.
+func quotedEchoV6(data []byte) (int, int, error) {
+	if len(data) < ipv6.HeaderLen+8 {
+		return 0, 0, fmt.Errorf("слишком короткое вложение ICMPv6")
+	}
+	if data[6] != protocolICMPv6 {
+		return 0, 0, fmt.Errorf("вложение не является ICMPv6")
+	}
+	echo := data[ipv6.HeaderLen:]
+	if ipv6.ICMPType(echo[0]) != ipv6.ICMPTypeEchoRequest {
+		return 0, 0, fmt.Errorf("вложение не является эхо-запросом")
+	}
+	return int(binary.BigEndian.Uint16(echo[4:6])), int(binary.BigEndian.Uint16(echo[6:8])), nil
+}
+
+// addrIP возвращает IP-адрес из net.Addr, полученного при чтении сокета
+func addrIP(addr net.Addr) net.IP {
+	switch v := addr.(type) {
+	case *net.IPAddr:
+		return v.IP
+	case *net.UDPAddr:
+		return v.IP
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// listenICMP открывает ICMP-сокет. Сначала пробуем непривилегированный
+// датаграммный сокет (разрешается через net.ipv4.ping_group_range),
+// при неудаче — raw-сокет, которому нужны root или CAP_NET_RAW.
+// Сокет привязывается к адресу src и интерфейсу iface, если они указаны.
+func listenICMP(src net.IP, iface string) (*icmpConn, error) {
+	c, dgramErr := listenICMPDatagram(false, src, iface)
+	if dgramErr == nil {
+		return c, nil
+	}
+
+	raw, rawErr := listenRawICMP("ip4:icmp", src, iface)
+	if rawErr != nil {
+		return nil, fmt.Errorf("%w: udp4: %v (%s); raw: %v",
+			errICMPSocket, dgramErr, pingGroupRangeHint(), rawErr)
+	}
+	return newRawICMPConn(raw), nil
+}
+
+// listenICMPv6 открывает ICMPv6-сокет так же, как listenICMP: сначала
+// датаграммный, затем raw
+func listenICMPv6(src net.IP, iface string) (*icmpConn, error) {
+	c, dgramErr := listenICMPDatagram(true, src, iface)
+	if dgramErr == nil {
+		return c, nil
+	}
+
+	raw, rawErr := listenRawICMP("ip6:ipv6-icmp", src, iface)
+	if rawErr != nil {
+		return nil, fmt.Errorf("%w (ICMPv6): udp6: %v (%s); raw: %v",
+			errICMPSocket, dgramErr, pingGroupRangeHint(), rawErr)
+	}
+	return newRawICMPv6Conn(raw), nil
+}
+
+// listenICMPDatagram открывает сокет SOCK_DGRAM/IPPROTO_ICMP (для v6 —
+// IPPROTO_ICMPV6) с включённым IP_RECVERR: без него ядро не передаёт такому
+// сокету Time Exceeded, а с ним эти сообщения попадают в очередь ошибок сокета.
+func listenICMPDatagram(v6 bool, src net.IP, iface string) (*icmpConn, error) {
+	domain, proto := unix.AF_INET, unix.IPPROTO_ICMP
+	level, opt, optName := unix.SOL_IP, unix.IP_RECVERR, "IP_RECVERR"
+	local4 := &unix.SockaddrInet4{}
+	var local unix.Sockaddr = local4
+	if src != nil {
+		copy(local4.Addr[:], src.To4())
+	}
+	if v6 {
+		domain, proto = unix.AF_INET6, unix.IPPROTO_ICMPV6
+		level, opt, optName = unix.SOL_IPV6, unix.IPV6_RECVERR, "IPV6_RECVERR"
+		local6 := &unix.SockaddrInet6{}
+		copy(local6.Addr[:], src.To16())
+		local = local6
+	}
+
+	fd, err := unix.Socket(domain, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.SetsockoptInt(fd, level, opt, 1); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("%s: %v", optName, err)
+	}
+	if iface != "" {
+		setBindToDevice(fd, iface)
+	}
+	if err := unix.Bind(fd, local); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind: %v", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "icmp-dgram")
+	conn, err := net.FilePacketConn(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// Ядро подменяет ID эхо-запроса на локальный "порт" сокета
+	id := 0
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+		id = addr.Port
+	}
+	if v6 {
+		return &icmpConn{conn: conn, p6: ipv6.NewPacketConn(conn), mode: icmpModeDatagram6, id: id}, nil
+	}
+	return &icmpConn{conn: conn, p4: ipv4.NewPacketConn(conn), mode: icmpModeDatagram, id: id}, nil
+}
+
+// pingGroupRangeHint поясняет, почему датаграммный ICMP-сокет недоступен
+func pingGroupRangeHint() string {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ping_group_range")
+	if err != nil {
+		return "net.ipv4.ping_group_range недоступен"
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return "net.ipv4.ping_group_range: " + strings.TrimSpace(string(data))
+	}
+	lo, _ := strconv.Atoi(fields[0])
+	hi, _ := strconv.Atoi(fields[1])
+	gid := os.Getgid()
+	if gid < lo || gid > hi {
+		return fmt.Sprintf("net.ipv4.ping_group_range = %d %d, gid %d не входит в диапазон", lo, hi, gid)
+	}
+	return fmt.Sprintf("net.ipv4.ping_group_range = %d %d", lo, hi)
+}
+
+// recvDatagram читает сначала очередь ошибок сокета (Time Exceeded,
+// Destination Unreachable), затем обычные эхо-ответы
+func (c *icmpConn) recvDatagram() (*icmpReply, error) {
+	sc, ok := c.conn.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("сокет не поддерживает SyscallConn")
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var reply *icmpReply
+	var opErr error
+	err = rc.Read(func(fd uintptr) bool {
+		reply, opErr = c.readErrQueue(int(fd))
+		if errors.Is(opErr, unix.EAGAIN) {
+			reply, opErr = c.readEcho(int(fd))
+		}
+		return !errors.Is(opErr, unix.EAGAIN)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply, opErr
+}
+
+// readErrQueue извлекает ICMP-ошибку из очереди ошибок сокета.
+// Адрес отправителя ошибки берётся из SO_EE_OFFENDER.
+// Данные сообщения — struct sock_extended_err (16 байт), за ним
+// struct sockaddr_in или struct sockaddr_in6 отправителя.
+func (c *icmpConn) readErrQueue(fd int) (*icmpReply, error) {
+	b := make([]byte, 1500)
+	oob := make([]byte, 512)
+	n, oobn, _, _, err := unix.Recvmsg(fd, b, oob, unix.MSG_ERRQUEUE|unix.MSG_DONTWAIT)
+	if err != nil {
+		return nil, err
+	}
+	cmsgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, nil
+	}
+	for _, m := range cmsgs {
+		if n < 8 {
+			return nil, nil
+		}
+		reply := &icmpReply{ID: c.id, Seq: int(binary.BigEndian.Uint16(b[6:8]))}
+		switch {
+		case m.Header.Level == unix.SOL_IP && m.Header.Type == unix.IP_RECVERR:
+			if len(m.Data) < 24 || m.Data[4] != unix.SO_EE_ORIGIN_ICMP {
+				return nil, nil
+			}
+			reply.Peer = net.IPv4(m.Data[20], m.Data[21], m.Data[22], m.Data[23])
+			reply.Type = ipv4.ICMPType(m.Data[5])
+		case m.Header.Level == unix.SOL_IPV6 && m.Header.Type == unix.IPV6_RECVERR:
+			if len(m.Data) < 40 || m.Data[4] != unix.SO_EE_ORIGIN_ICMP6 {
+				return nil, nil
+			}
+			reply.Peer = net.IP(append([]byte(nil), m.Data[24:40]...))
+			reply.Type = ipv6.ICMPType(m.Data[5])
+		default:
+			continue
+		}
+		return reply, nil
+	}
+	return nil, nil
+}
+
+// readEcho читает эхо-ответ из датаграммного сокета
+func (c *icmpConn) readEcho(fd int) (*icmpReply, error) {
+	b := make([]byte, 1500)
+	n, from, err := unix.Recvfrom(fd, b, unix.MSG_DONTWAIT)
+	if err != nil {
+		return nil, err
+	}
+	var peer net.IP
+	proto := protocolICMP
+	switch sa := from.(type) {
+	case *unix.SockaddrInet4:
+		peer = net.IP(sa.Addr[:])
+	case *unix.SockaddrInet6:
+		peer = net.IP(sa.Addr[:])
+		proto = protocolICMPv6
+	}
+	msg, err := icmp.ParseMessage(proto, b[:n])
+	if err != nil {
+		return nil, nil
+	}
+	echo, ok := msg.Body.(*icmp.Echo)
+	reply := &icmpReply{Peer: peer, Type: msg.Type}
+	if !ok || !reply.isEchoReply() {
+		return nil, nil
+	}
+	reply.ID, reply.Seq = echo.ID, echo.Seq
+	return reply, nil
+}
@@ -0,0 +1,32 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// listenICMP открывает raw ICMP-сокет на адресе src (nil — на всех адресах).
+// На Windows для этого нужны права администратора.
+func listenICMP(src net.IP, iface string) (*icmpConn, error) {
+	raw, err := listenRawICMP("ip4:icmp", src, iface)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errICMPSocket, err)
+	}
+	return newRawICMPConn(raw), nil
+}
+
+// listenICMPv6 открывает raw ICMPv6-сокет
+func listenICMPv6(src net.IP, iface string) (*icmpConn, error) {
+	raw, err := listenRawICMP("ip6:ipv6-icmp", src, iface)
+	if err != nil {
+		return nil, fmt.Errorf("%w (ICMPv6): %v", errICMPSocket, err)
+	}
+	return newRawICMPv6Conn(raw), nil
+}
+
+// recvDatagram не используется: вне Linux открывается только raw-сокет
+func (c *icmpConn) recvDatagram() (*icmpReply, error) {
+	return nil, fmt.Errorf("датаграммный ICMP-сокет не поддерживается")
+}
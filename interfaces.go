@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// netInterface — активный сетевой интерфейс устройства
+type netInterface struct {
+	Name         string
+	MTU          int
+	Addrs        []string // Адреса с длиной префикса: 192.168.1.10/24, fe80::1/64
+	DefaultRoute bool     // Через интерфейс идёт маршрут по умолчанию
+}
+
+// String описывает интерфейс для лога и GUI
+func (i netInterface) String() string {
+	s := fmt.Sprintf("%s (MTU %d", i.Name, i.MTU)
+	if len(i.Addrs) > 0 {
+		s += ", " + strings.Join(i.Addrs, ", ")
+	}
+	if i.DefaultRoute {
+		s += ", маршрут по умолчанию"
+	}
+	return s + ")"
+}
+
+// listInterfaces возвращает активные интерфейсы, кроме loopback. routes —
+// маршруты по умолчанию, по ним отмечаются интерфейсы с маршрутом по умолчанию.
+func listInterfaces(routes []defaultRoute) ([]netInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("Ошибка при получении сетевых интерфейсов: %v", err)
+	}
+
+	var list []netInterface
+	for _, iface := range ifaces {
+		// Пропускаем неактивные интерфейсы и loopback
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ni := netInterface{Name: iface.Name, MTU: iface.MTU}
+		ni.DefaultRoute = slices.ContainsFunc(routes, func(r defaultRoute) bool { return r.Interface == iface.Name })
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				ni.Addrs = append(ni.Addrs, addr.String())
+			}
+		}
+		list = append(list, ni)
+	}
+	return list, nil
+}
+
+// interfaceIPs возвращает первый адрес IPv4 и первый глобальный адрес IPv6 интерфейса
+func interfaceIPs(iface string) (v4, v6 net.IP, err error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, nil, fmt.Errorf("интерфейс %s не найден: %v", iface, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось получить адреса интерфейса %s: %v", iface, err)
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		switch ip := ipnet.IP; {
+		case ip.To4() != nil:
+			if v4 == nil {
+				v4 = ip.To4()
+			}
+		case ip.IsGlobalUnicast():
+			if v6 == nil {
+				v6 = ip
+			}
+		}
+	}
+	return v4, v6, nil
+}
+
+// sourceIP возвращает адрес интерфейса iface семейства family, к которому
+// привязываются сокеты проверок. Без семейства предпочитается IPv4.
+func sourceIP(iface, family string) (net.IP, error) {
+	v4, v6, err := interfaceIPs(iface)
+	if err != nil {
+		return nil, err
+	}
+	ip := v4
+	switch {
+	case family == familyIPv6, family == "" && v4 == nil:
+		ip, family = v6, familyIPv6
+	case family == "":
+		family = familyIPv4
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("у интерфейса %s нет адреса %s", iface, family)
+	}
+	return ip, nil
+}
+
+// sourceDialer возвращает net.Dialer для проверки с интерфейса iface:
+// исходящие соединения привязываются к его адресу семейства family и, где
+// это возможно, к самому интерфейсу. network — "tcp" или "udp", от него
+// зависит тип локального адреса. Без интерфейса возвращается обычный Dialer.
+func sourceDialer(iface, family, network string, timeout time.Duration) (*net.Dialer, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	if iface == "" {
+		return dialer, nil
+	}
+	ip, err := sourceIP(iface, family)
+	if err != nil {
+		return nil, err
+	}
+	if network == "udp" {
+		dialer.LocalAddr = &net.UDPAddr{IP: ip}
+	} else {
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+	dialer.Control = bindToDevice(iface)
+	return dialer, nil
+}
+
+// withInterface добавляет к записи хоста интерфейс, с которого он проверяется:
+// "8.8.8.8 @wg0". Имя интерфейса может содержать пробелы ("Ethernet 2").
+func withInterface(host, iface string) string {
+	if iface == "" {
+		return host
+	}
+	return host + " @" + iface
+}
+
+// splitInterface отделяет от записи хоста интерфейс. Для записи без
+// интерфейса iface пустое.
+func splitInterface(host string) (base, iface string) {
+	if i := strings.LastIndex(host, " @"); i >= 0 {
+		return strings.TrimSpace(host[:i]), strings.TrimSpace(host[i+2:])
+	}
+	return host, ""
+}
+
+// splitHostKey разбирает запись хоста на исходную запись, семейство адресов
+// и интерфейс: "ya.ru [IPv6] @wg0" → "ya.ru", "IPv6", "wg0"
+func splitHostKey(key string) (base, family, iface string) {
+	base, iface = splitInterface(key)
+	base, family = splitFamily(base)
+	return base, family, iface
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindToDevice возвращает Control для net.Dialer и net.ListenConfig, который
+// привязывает сокет к интерфейсу (SO_BINDTODEVICE). Тогда пакеты уходят
+// через интерфейс даже при маршруте по умолчанию через другой, например через
+// VPN. Без CAP_NET_RAW привязка может не удаться, и остаётся привязка к адресу.
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		return c.Control(func(fd uintptr) {
+			setBindToDevice(int(fd), iface)
+		})
+	}
+}
+
+// setBindToDevice привязывает сокет к интерфейсу, ошибка не считается фатальной
+func setBindToDevice(fd int, iface string) {
+	unix.SetsockoptString(fd, unix.SOL_SOCKET, unix.SO_BINDTODEVICE, iface)
+}
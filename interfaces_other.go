@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "syscall"
+
+// bindToDevice вне Linux не используется: сокет привязывается только
+// к адресу интерфейса
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// Семейства адресов. Имя, у которого есть и A-, и AAAA-записи, проверяется
+// по каждому семейству отдельно: к записи хоста добавляется суффикс
+// " [IPv4]" или " [IPv6]", и статистика ведётся по каждой записи своя.
+// Суффикс можно указать и в конфигурации, чтобы проверять только одно семейство.
+const (
+	familyIPv4 = "IPv4"
+	familyIPv6 = "IPv6"
+)
+
+// withFamily добавляет к записи хоста суффикс семейства адресов
+func withFamily(host, family string) string {
+	if family == "" {
+		return host
+	}
+	return host + " [" + family + "]"
+}
+
+// splitFamily отделяет от записи хоста суффикс семейства адресов.
+// Для записи без суффикса family пустое.
+func splitFamily(host string) (base, family string) {
+	for _, f := range []string{familyIPv4, familyIPv6} {
+		if b, ok := strings.CutSuffix(host, " ["+f+"]"); ok {
+			return strings.TrimSpace(b), f
+		}
+	}
+	return host, ""
+}
+
+// familyNetwork уточняет сеть для net.Dial и net.ResolveIPAddr семейством
+// адресов: "tcp" → "tcp4" или "tcp6". Без семейства сеть не меняется.
+func familyNetwork(network, family string) string {
+	switch family {
+	case familyIPv4:
+		return network + "4"
+	case familyIPv6:
+		return network + "6"
+	}
+	return network
+}
+
+// ipFamily возвращает семейство IP-адреса
+func ipFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return familyIPv4
+	}
+	return familyIPv6
+}
+
+// filterFamily оставляет адреса указанного семейства; без семейства
+// возвращает все адреса
+func filterFamily(ips []net.IPAddr, family string) []net.IPAddr {
+	if family == "" {
+		return ips
+	}
+	var filtered []net.IPAddr
+	for _, ip := range ips {
+		if ipFamily(ip.IP) == family {
+			filtered = append(filtered, ip)
+		}
+	}
+	return filtered
+}
+
+// hasGlobalIPv6 сообщает, есть ли глобальный адрес IPv6 у интерфейса iface
+// или, если интерфейс не указан, у устройства
+func hasGlobalIPv6(iface string) bool {
+	if iface != "" {
+		_, v6, err := interfaceIPs(iface)
+		return err == nil && v6 != nil
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() == nil && ipnet.IP.IsGlobalUnicast() {
+			return true
+		}
+	}
+	return false
+}
+
+// dualStackHosts возвращает записи хоста по семействам адресов, если имя
+// t.Host разрешается и в IPv4, и в IPv6, а у устройства (или у интерфейса
+// проверки) есть адрес IPv6.
+// Иначе, а также для IP-адресов и записей с явным семейством возвращается
+// сама запись.
+func dualStackHosts(ctx context.Context, t Target) []string {
+	// Без своего адреса IPv6 строки IPv6 показывали бы только потери
+	if t.Family != "" || net.ParseIP(t.Host) != nil || !hasGlobalIPv6(t.Interface) {
+		return []string{t.Raw}
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, t.Host)
+	if err != nil {
+		// Ошибку разрешения покажет сама проверка
+		return []string{t.Raw}
+	}
+	if len(filterFamily(ips, familyIPv4)) == 0 || len(filterFamily(ips, familyIPv6)) == 0 {
+		return []string{t.Raw}
+	}
+	base, iface := splitInterface(t.Raw)
+	return []string{withInterface(withFamily(base, familyIPv4), iface), withInterface(withFamily(base, familyIPv6), iface)}
+}
@@ -1,34 +1,74 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/transform"
 )
 
-// Функция для пинга адреса с использованием системной утилиты ping
-func pingHost(host string, wg *sync.WaitGroup, results chan<- string) {
-	defer wg.Done()
+// familyFlag возвращает флаг -4 или -6, которым ping, mtr и tracert
+// выбирают семейство адресов
+func familyFlag(family string) []string {
+	switch family {
+	case familyIPv4:
+		return []string{"-4"}
+	case familyIPv6:
+		return []string{"-6"}
+	}
+	return nil
+}
 
+// Функция для пинга адреса с использованием системной утилиты ping.
+// t.Family выбирает семейство адресов для имени с записями A и AAAA,
+// t.Interface — интерфейс, с которого отправляются запросы.
+// Возвращает статистику и текст для лога.
+func pingHostExec(ctx context.Context, t Target, probe ProbeOptions) (*PingStats, string, error) {
+	host := t.Host
+	count := strconv.Itoa(probe.Count)
+	size := strconv.Itoa(probe.Size)
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
-		cmd = exec.Command("ping", "-n", "4", "-w", "1000", host)
+		timeout := strconv.Itoa(int(probe.Timeout.Milliseconds()))
+		args := append(familyFlag(t.Family), "-n", count, "-w", timeout, "-l", size)
+		if t.Interface != "" {
+			// ping в Windows выбирает интерфейс только по адресу источника
+			src, err := sourceIP(t.Interface, t.addrFamily())
+			if err != nil {
+				return nil, "", err
+			}
+			args = append(args, "-S", src.String())
+		}
+		cmd = exec.CommandContext(ctx, "ping", append(args, host)...)
 	} else {
-		cmd = exec.Command("ping", "-c", "4", "-W", "1", host)
+		// -W принимает целые секунды
+		timeout := strconv.Itoa(int(math.Ceil(probe.Timeout.Seconds())))
+		args := append(familyFlag(t.Family), "-c", count, "-W", timeout, "-s", size)
+		if t.Interface != "" {
+			args = append(args, "-I", t.Interface)
+		}
+		if probe.Interval != time.Second {
+			// Не все реализации ping (например, busybox) знают -i
+			args = append(args, "-i", strconv.FormatFloat(probe.Interval.Seconds(), 'f', -1, 64))
+		}
+		cmd = exec.CommandContext(ctx, "ping", append(args, host)...)
 	}
 
 	output, err := cmd.CombinedOutput()
@@ -41,10 +81,9 @@ func pingHost(host string, wg *sync.WaitGroup, results chan<- string) {
 			AvgRTT:     0,
 			PacketLoss: 100, // 100% потерь при ошибке
 			LastUpdate: time.Now(),
+			Sent:       probe.Count,
 		}
-		updateStatsMap(host, stats)
-		results <- fmt.Sprintf("Ошибка при пинге %s: %v\n%s", host, err, output)
-		return
+		return stats, fmt.Sprintf("Ошибка при пинге %s: %v\n%s", host, err, output), nil
 	}
 
 	// Конвертируем вывод в UTF-8 для Windows
@@ -53,26 +92,31 @@ func pingHost(host string, wg *sync.WaitGroup, results chan<- string) {
 		reader := transform.NewReader(bytes.NewReader(output), decoder)
 		output, err = io.ReadAll(reader)
 		if err != nil {
-			results <- fmt.Sprintf("Ошибка при конвертации кодировки для %s: %v", host, err)
-			return
+			return nil, "", fmt.Errorf("ошибка при конвертации кодировки для %s: %v", host, err)
 		}
 	}
 
 	// Парсим статистику
-	stats := parsePingStats(string(output), host)
+	stats := parsePingStats(string(output), host, probe.Count)
 	if stats.PacketLoss == 100 {
 		// Если все пакеты потеряны, устанавливаем время в 0
 		stats.MinRTT = 0
 		stats.MaxRTT = 0
 		stats.AvgRTT = 0
+		stats.Samples = nil
+		stats.Received = 0
 	}
-	updateStatsMap(host, stats)
 
-	results <- fmt.Sprintf("Результаты пинга для %s:\n%s", host, output)
+	return stats, string(output), nil
 }
 
-// Функция для парсинга статистики пинга
-func parsePingStats(output, host string) *PingStats {
+// RTT отдельного пакета: "time=0.045 ms" в Linux, "время=12мс" или
+// "time<1ms" в Windows
+var packetRTTRe = regexp.MustCompile(`(?:time|время)[=<]\s*(\d+(?:[.,]\d+)?)\s*(?:ms|мс)`)
+
+// Функция для парсинга статистики пинга. count — число отправленных пакетов,
+// если вывод его не содержит.
+func parsePingStats(output, host string, count int) *PingStats {
 	stats := &PingStats{
 		Host:       host,
 		LastUpdate: time.Now(),
@@ -84,13 +128,24 @@ func parsePingStats(output, host string) *PingStats {
 	lossRe := regexp.MustCompile(`(\d+)% packet loss`)
 	transmittedRe := regexp.MustCompile(`(\d+) packets transmitted, (\d+) received`)
 
+	// Собираем RTT отдельных пакетов
+	for _, matches := range packetRTTRe.FindAllStringSubmatch(output, -1) {
+		if rtt, err := strconv.ParseFloat(strings.Replace(matches[1], ",", ".", 1), 64); err == nil {
+			stats.Samples = append(stats.Samples, rtt)
+		}
+	}
+
 	// Ищем информацию о переданных и полученных пакетах
 	if matches := transmittedRe.FindStringSubmatch(output); len(matches) > 2 {
 		transmitted, _ := strconv.Atoi(matches[1])
 		received, _ := strconv.Atoi(matches[2])
+		stats.Sent, stats.Received = transmitted, received
 		if transmitted > 0 {
 			stats.PacketLoss = float64(transmitted-received) * 100 / float64(transmitted)
 		}
+	} else {
+		// Итоговая строка локализована (Windows), считаем по ответам
+		stats.Sent, stats.Received = count, len(stats.Samples)
 	}
 
 	// Ищем минимальное, среднее и максимальное время
@@ -106,18 +161,6 @@ func parsePingStats(output, host string) *PingStats {
 	return stats
 }
 
-// Функция для обновления карты статистики
-func updateStatsMap(host string, stats *PingStats) {
-	statsMutex.Lock()
-	defer statsMutex.Unlock()
-	statsMap[host] = stats
-
-	// Обновляем статистику в файле
-	if err := updatePingStats(host, stats); err != nil {
-		log.Printf("Ошибка при обновлении файла статистики: %v", err)
-	}
-}
-
 // Функция для трассировки маршрута до хоста
 func traceRoute(host string) (string, error) {
 	var cmd *exec.Cmd
@@ -134,6 +177,9 @@ func traceRoute(host string) (string, error) {
 	return string(output), nil
 }
 
+// hopAddrRe находит в выводе tracert адреса IPv4 и IPv6
+var hopAddrRe = regexp.MustCompile(`\d+\.\d+\.\d+\.\d+|[0-9a-fA-F]*:[0-9a-fA-F:]*:[0-9a-fA-F.]*`)
+
 // Функция для извлечения первых 3 хопов из вывода traceroute
 func getFirstThreeHops(host string) ([]string, error) {
 	var cmd *exec.Cmd
@@ -151,31 +197,31 @@ func getFirstThreeHops(host string) ([]string, error) {
 	lines := strings.Split(string(output), "\n")
 	hops := []string{}
 	if runtime.GOOS == "windows" {
-		// Для Windows ищем IP-адреса без скобок
-		ipRe := regexp.MustCompile(`(\d+\.\d+\.\d+\.\d+)`)
+		// Для Windows ищем IP-адреса без скобок, IPv6 может быть в квадратных
 		for _, line := range lines {
 			if len(hops) >= 3 {
 				break
 			}
-			matches := ipRe.FindAllString(line, -1)
-			for _, ip := range matches {
-				if ip != "0.0.0.0" && ip != "127.0.0.1" && !strings.HasPrefix(ip, "192.168.") {
-					hops = append(hops, ip)
-					if len(hops) >= 3 {
-						break
-					}
+			for _, ip := range hopAddrRe.FindAllString(line, -1) {
+				parsed := net.ParseIP(ip)
+				if parsed == nil || parsed.IsUnspecified() || parsed.IsLoopback() || parsed.IsLinkLocalUnicast() || strings.HasPrefix(ip, "192.168.") {
+					continue
+				}
+				hops = append(hops, ip)
+				if len(hops) >= 3 {
+					break
 				}
 			}
 		}
 	} else {
 		// Для Linux ищем IP-адреса в скобках
-		re := regexp.MustCompile(`\((\d+\.\d+\.\d+\.\d+)\)`)
+		re := regexp.MustCompile(`\(([0-9a-fA-F:.]+)\)`)
 		for i, line := range lines {
 			if i >= 3 {
 				break
 			}
 			matches := re.FindStringSubmatch(line)
-			if len(matches) > 1 {
+			if len(matches) > 1 && net.ParseIP(matches[1]) != nil {
 				hops = append(hops, matches[1])
 			}
 		}
@@ -188,44 +234,31 @@ func getFirstThreeHops(host string) ([]string, error) {
 	return hops, nil
 }
 
-// Функция для получения IP-адреса устройства в Linux и Windows
-func getDeviceIP() (string, error) {
-	ifaces, err := net.Interfaces()
-	if err != nil {
-		return "", fmt.Errorf("Ошибка при получении сетевых интерфейсов: %v", err)
-	}
-
+// Функция для получения IP-адресов устройства в Linux и Windows: первого
+// адреса IPv4 и первого глобального адреса IPv6 каждого интерфейса, через
+// который идёт маршрут по умолчанию. Если таких интерфейсов нет (маршруты
+// не удалось прочитать), берутся адреса всех интерфейсов.
+func getDeviceIPs(ifaces []netInterface) ([]string, error) {
+	withRoute := slices.ContainsFunc(ifaces, func(i netInterface) bool { return i.DefaultRoute })
+	var ips []string
 	for _, iface := range ifaces {
-		// Пропускаем неактивные интерфейсы и loopback
-		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+		if withRoute && !iface.DefaultRoute {
 			continue
 		}
-
-		addrs, err := iface.Addrs()
+		v4, v6, err := interfaceIPs(iface.Name)
 		if err != nil {
 			continue
 		}
-
-		for _, addr := range addrs {
-			// Преобразуем адрес в IP
-			var ip net.IP
-			switch v := addr.(type) {
-			case *net.IPNet:
-				ip = v.IP
-			case *net.IPAddr:
-				ip = v.IP
+		for _, ip := range []net.IP{v4, v6} {
+			if ip != nil {
+				ips = append(ips, ip.String())
 			}
-
-			// Пропускаем IPv6 и локальные адреса
-			if ip == nil || ip.IsLoopback() || ip.To4() == nil {
-				continue
-			}
-
-			return ip.String(), nil
 		}
 	}
-
-	return "", fmt.Errorf("Не удалось найти IP-адрес устройства")
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("Не удалось найти IP-адрес устройства")
+	}
+	return uniqueHosts(ips), nil
 }
 
 // Функция для проверки доступности утилиты
@@ -239,166 +272,309 @@ func checkCommandAvailable(cmd string) bool {
 	return checkCmd.Run() == nil
 }
 
-// Функция для запуска MTR до указанного хоста
-func runMTR(host string, maxHops int) error {
+// Функция для запуска MTR до указанного хоста. Трассировка идёт до отмены ctx,
+// update получает текущий отчёт и статистику по хопам (nil для tracert) после
+// каждого обновления. Возвращает отчёт на момент остановки, в том числе
+// частичный, если трассировку прервали. Суффикс " [IPv6]" или " [IPv4]"
+// у host выбирает семейство адресов, без него предпочитается IPv4;
+// суффикс " @wg0" — интерфейс, с которого идёт трассировка.
+func runMTR(ctx context.Context, host string, maxHops int, update func(string, []WinMTRHop)) (string, error) {
+	host = strings.TrimSpace(host)
+
+	// Сначала пробуем встроенную ICMP-трассировку
+	timeout := 2 * time.Second
+	hops, mode, err := continuousMTR(ctx, host, maxHops, timeout, time.Second, func(hops []WinMTRHop, mode icmpMode) {
+		update(formatMTRReport(mode, hops), hops)
+	})
+	if err == nil {
+		return formatMTRReport(mode, hops), nil
+	}
+	log.Printf("Встроенная трассировка недоступна: %v, пробуем внешнюю утилиту", err)
+	host, family, iface := splitHostKey(host)
+
 	var cmd *exec.Cmd
 	if runtime.GOOS == "windows" {
 		if !checkCommandAvailable("tracert") {
-			return fmt.Errorf("утилита tracert не найдена в системе")
+			return "", fmt.Errorf("встроенная трассировка недоступна (%v), а утилита tracert не найдена в системе", err)
 		}
-		cmd = exec.Command("tracert", "-h", strconv.Itoa(maxHops), host)
-	} else {
-		if !checkCommandAvailable("mtr") {
-			return fmt.Errorf("утилита mtr не найдена в системе. Установите её с помощью: sudo apt-get install mtr")
+		args := append(familyFlag(family), "-h", strconv.Itoa(maxHops))
+		if iface != "" {
+			src, err := sourceIP(iface, family)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, "-S", src.String())
 		}
-		cmd = exec.Command("mtr", "-n", "-r", "-c", "1", "-m", strconv.Itoa(maxHops), host)
+		cmd = exec.CommandContext(ctx, "tracert", append(args, host)...)
+		return runTracertCommand(ctx, cmd, update)
 	}
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("Ошибка при запуске MTR: %v", err)
+	if !checkCommandAvailable("mtr") {
+		return "", fmt.Errorf("встроенная трассировка недоступна (%v), а утилита mtr не найдена в системе. Установите её с помощью: sudo apt-get install mtr", err)
 	}
-
-	// Конвертируем вывод в UTF-8 для Windows
-	if runtime.GOOS == "windows" {
-		decoder := charmap.Windows1251.NewDecoder()
-		reader := transform.NewReader(bytes.NewReader(output), decoder)
-		output, err = io.ReadAll(reader)
-		if err != nil {
-			return fmt.Errorf("Ошибка при конвертации кодировки: %v", err)
-		}
+	// В режиме --raw mtr печатает каждый ответ сразу, поэтому при отмене
+	// остаётся статистика, накопленная до остановки
+	args := append(familyFlag(family), "-n", "--raw", "-c", "86400", "-m", strconv.Itoa(maxHops))
+	if iface != "" {
+		args = append(args, "-I", iface)
 	}
+	cmd = exec.CommandContext(ctx, "mtr", append(args, host)...)
+	return runMTRCommand(ctx, cmd, maxHops, update)
+}
 
-	log.Printf("Результаты MTR до %s:\n%s", host, string(output))
-	return nil
+// formatMTRReport формирует текстовый отчёт по хопам с указанием режима ICMP
+func formatMTRReport(mode icmpMode, hops []WinMTRHop) string {
+	return fmt.Sprintf("Режим ICMP: %s\n\n%s", mode, FormatWinMTRResult(hops))
 }
 
-func startPingCollection(hosts []string) {
-	if len(hosts) == 0 {
-		log.Println("Не указаны хосты для пинга")
-		return
+// runMTRCommand запускает mtr --raw и собирает статистику по хопам из его
+// вывода. Процесс завершается при отмене ctx.
+func runMTRCommand(ctx context.Context, cmd *exec.Cmd, maxHops int, update func(string, []WinMTRHop)) (string, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("Ошибка при запуске MTR: %v", err)
 	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("Ошибка при запуске MTR: %v", err)
+	}
+	// Закрываем канал вывода при отмене, чтобы не ждать дочерние процессы,
+	// которые могли унаследовать его
+	stopClose := context.AfterFunc(ctx, func() { stdout.Close() })
+	defer stopClose()
 
-	// Канал для сбора результатов пинга
-	results := make(chan string, len(hosts))
-	var wg sync.WaitGroup
+	hops := make([]WinMTRHop, maxHops)
+	for i := range hops {
+		hops[i] = WinMTRHop{Hop: i + 1, Address: "*"}
+	}
+	last := 0 // Номер последнего хопа, от которого был ответ
+	report := func() string {
+		return "Утилита: mtr\n\n" + FormatWinMTRResult(hops[:last])
+	}
 
-	// Пинг каждого хоста параллельно
-	for _, host := range hosts {
-		if host == "" {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		pos, ok := parseMTRRawLine(scanner.Text(), hops)
+		if !ok {
 			continue
 		}
-		wg.Add(1)
-		go pingHost(host, &wg, results)
+		if pos+1 > last {
+			last = pos + 1
+		}
+		update(report(), hops[:last])
 	}
 
-	// Запускаем горутину для закрытия канала после завершения всех пингов
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	err = cmd.Wait()
+	if err != nil && ctx.Err() == nil {
+		return report(), fmt.Errorf("Ошибка при запуске MTR: %v", err)
+	}
+	return report(), nil
+}
 
-	// Читаем результаты из канала
-	for result := range results {
-		log.Println(result)
+// parseMTRRawLine разбирает строку вывода mtr --raw:
+//
+//	x <хоп> <seq>          — отправлен зонд
+//	h <хоп> <адрес>        — адрес хопа
+//	p <хоп> <мкс> [<seq>]  — получен ответ
+//
+// Возвращает номер хопа (с нуля), если строка изменила статистику.
+func parseMTRRawLine(line string, hops []WinMTRHop) (int, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return 0, false
+	}
+	pos, err := strconv.Atoi(fields[1])
+	if err != nil || pos < 0 || pos >= len(hops) {
+		return 0, false
+	}
+	hop := &hops[pos]
+	switch fields[0] {
+	case "x":
+		hop.Sent++
+	case "h":
+		hop.Address = fields[2]
+		hop.Success = true
+	case "p":
+		usec, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, false
+		}
+		hop.record(time.Duration(usec) * time.Microsecond)
+		// Старые версии mtr не печатают строки "x"
+		if hop.Sent < hop.Received {
+			hop.Sent = hop.Received
+		}
+	default:
+		return 0, false
 	}
+	hop.updateLoss()
+	return pos, true
+}
 
-	log.Println("------------")
-	log.Println("Завершено выполнение программы.")
+// runTracertCommand запускает tracert и передаёт его вывод построчно.
+// Процесс завершается при отмене ctx.
+func runTracertCommand(ctx context.Context, cmd *exec.Cmd, update func(string, []WinMTRHop)) (string, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("Ошибка при запуске MTR: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("Ошибка при запуске MTR: %v", err)
+	}
+	// Закрываем канал вывода при отмене, чтобы не ждать дочерние процессы,
+	// которые могли унаследовать его
+	stopClose := context.AfterFunc(ctx, func() { stdout.Close() })
+	defer stopClose()
+
+	// Конвертируем вывод в UTF-8 для Windows
+	decoder := charmap.Windows1251.NewDecoder()
+	scanner := bufio.NewScanner(transform.NewReader(stdout, decoder))
+	var output strings.Builder
+	for scanner.Scan() {
+		output.WriteString(scanner.Text())
+		output.WriteString("\n")
+		update(output.String(), nil)
+	}
+
+	err = cmd.Wait()
+	if err != nil && ctx.Err() == nil {
+		return output.String(), fmt.Errorf("Ошибка при запуске MTR: %v", err)
+	}
+	return output.String(), nil
 }
 
 // Функция для сбора информации о сети
 func collectNetworkInfo() ([]string, error) {
 	var hosts []string
 
-	// Получаем IP устройства
-	deviceIP, err := getDeviceIP()
+	// Получаем маршруты по умолчанию IPv4 и IPv6 из таблицы маршрутизации
+	routes, err := getDefaultRoutes()
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при получении IP устройства: %v", err)
+		log.Printf("Предупреждение: не удалось получить маршруты по умолчанию: %v", err)
+	}
+	for _, route := range routes {
+		log.Printf("Маршрут по умолчанию %s", route)
 	}
-	hosts = append(hosts, deviceIP)
-	log.Printf("IP адрес устройства: %s", deviceIP)
 
-	// Получаем шлюз по умолчанию
-	gateway, err := getDefaultGateway()
+	// Перечисляем интерфейсы и получаем IP устройства
+	ifaces, err := listInterfaces(routes)
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		log.Printf("Интерфейс %s", iface)
+	}
+	deviceIPs, err := getDeviceIPs(ifaces)
 	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении IP устройства: %v", err)
+	}
+	hosts = append(hosts, deviceIPs...)
+	log.Printf("IP адреса устройства: %v", deviceIPs)
+	hasIPv6 := slices.ContainsFunc(deviceIPs, func(ip string) bool { return strings.Contains(ip, ":") })
+	if gateways, err := getDefaultGateways(routes); err != nil {
 		log.Printf("Предупреждение: не удалось получить шлюз по умолчанию: %v", err)
 	} else {
-		hosts = append(hosts, gateway)
-		log.Printf("Шлюз по умолчанию: %s", gateway)
+		hosts = append(hosts, gateways...)
+		log.Printf("Шлюзы по умолчанию: %v", gateways)
 	}
 
-	// Получаем первые 3 хопа до 8.8.8.8
-	hops, err := getFirstThreeHops("8.8.8.8")
-	if err != nil {
-		log.Printf("Предупреждение: не удалось получить хопы до 8.8.8.8: %v", err)
-	} else {
+	// Получаем первые 3 хопа до 8.8.8.8 и при наличии IPv6 — до его IPv6-адреса
+	resolvers := defaultDNSResolvers
+	hopTargets := []string{"8.8.8.8"}
+	if hasIPv6 {
+		resolvers = append(slices.Clip(resolvers), defaultDNSResolversV6...)
+		hopTargets = append(hopTargets, defaultDNSResolversV6[0])
+	}
+	for _, target := range hopTargets {
+		hops, err := getFirstThreeHops(target)
+		if err != nil {
+			log.Printf("Предупреждение: не удалось получить хопы до %s: %v", target, err)
+			continue
+		}
 		hosts = append(hosts, hops...)
-		log.Printf("Первые 3 хопа до 8.8.8.8: %v", hops)
+		log.Printf("Первые 3 хопа до %s: %v", target, hops)
 	}
 
-	// Добавляем стандартные DNS-серверы
-	hosts = append(hosts, "8.8.8.8", "1.1.1.1", "77.88.8.8")
+	// Добавляем стандартные DNS-серверы: пинг и время разрешения имени
+	hosts = append(hosts, resolvers...)
+	for _, resolver := range resolvers {
+		hosts = append(hosts, schemeDNS+"://"+urlHost(resolver))
+	}
 
 	return hosts, nil
 }
 
-// Функция для получения шлюза по умолчанию
-func getDefaultGateway() (string, error) {
-	if runtime.GOOS == "windows" {
-		cmd := exec.Command("ipconfig")
-		output, err := cmd.CombinedOutput()
-		if err != nil {
-			return "", err
+// urlHost возвращает адрес для записи в URL: IPv6 — в квадратных скобках
+func urlHost(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+func main() {
+	configFlag := flag.String("config", defaultConfigPath, "файл конфигурации TOML с хостами и параметрами пинга")
+	headless := flag.Bool("headless", false, "работать без GUI: сбор статистики до SIGINT/SIGTERM (для серверов и systemd)")
+	intervalFlag := flag.Int("interval", defaultConfig().Interval, "интервал между циклами пинга, сек (5-3600); по умолчанию из конфигурации")
+	hostsFlag := flag.String("hosts", "", "дополнительные хосты для пинга через запятую")
+	durationFlag := flag.String("duration", "", "длительность сбора без GUI: 30m, 2h30m или до ЧЧ:ММ; по умолчанию без ограничения")
+	metricsFlag := flag.String("metrics", "", "адрес HTTP-сервера метрик Prometheus, например :9101; по умолчанию из конфигурации")
+	exportFlag := flag.String("export-csv", "", "выгрузить историю измерений в CSV-файл (- — в стандартный вывод) и завершить работу")
+	fromFlag := flag.String("from", "24h", "начало периода выгрузки: 24h (назад), 2006-01-02, \"2006-01-02 15:04\" или RFC 3339")
+	toFlag := flag.String("to", "", "конец периода выгрузки в том же формате; по умолчанию текущее время")
+	resolutionFlag := flag.String("resolution", "", "разрешение выгрузки: raw, 1m или 1h; по умолчанию самое подробное из сохранившихся")
+	flag.Parse()
+
+	// Флаги, указанные явно, имеют приоритет над файлом конфигурации
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	cfg, err := loadConfig(*configFlag, explicit["config"])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if explicit["interval"] {
+		if *intervalFlag < 5 || *intervalFlag > 3600 {
+			log.Fatal("Интервал должен быть от 5 до 3600 секунд")
 		}
+		cfg.Interval = *intervalFlag
+	}
+	if explicit["metrics"] {
+		cfg.MetricsListen = *metricsFlag
+	}
+	opts := cfg.monitorOptions()
+	opts.End, err = parseSessionEnd(*durationFlag, time.Now())
+	if err != nil {
+		log.Fatalf("Неверная длительность сбора: %v", err)
+	}
+	monitor := NewMonitor(opts)
+	monitor.SetHostInfo(cfg.hostInfo())
 
-		// Конвертируем вывод в UTF-8 для Windows
-		decoder := charmap.Windows1251.NewDecoder()
-		reader := transform.NewReader(bytes.NewReader(output), decoder)
-		output, err = io.ReadAll(reader)
+	// Выгрузка истории в CSV без сбора статистики
+	if *exportFlag != "" {
+		now := time.Now()
+		from, err := parseExportTime(*fromFlag, now)
 		if err != nil {
-			return "", err
+			log.Fatalf("Неверное начало периода: %v", err)
 		}
-
-		// Ищем строку с Default Gateway
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "Default Gateway") {
-				parts := strings.Split(line, ":")
-				if len(parts) > 1 {
-					gateway := strings.TrimSpace(parts[1])
-					if gateway != "" && gateway != "0.0.0.0" {
-						return gateway, nil
-					}
-				}
+		to := now
+		if *toFlag != "" {
+			if to, err = parseExportTime(*toFlag, now); err != nil {
+				log.Fatalf("Неверный конец периода: %v", err)
 			}
 		}
-	} else {
-		cmd := exec.Command("ip", "route", "show", "default")
-		output, err := cmd.CombinedOutput()
+		res, err := parseHistoryResolution(*resolutionFlag)
 		if err != nil {
-			return "", err
+			log.Fatalf("Неверное разрешение: %v", err)
 		}
-
-		// Ищем IP-адрес после "via"
-		lines := strings.Split(string(output), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, "via") {
-				parts := strings.Split(line, "via")
-				if len(parts) > 1 {
-					gateway := strings.Fields(parts[1])[0]
-					if gateway != "" && gateway != "0.0.0.0" {
-						return gateway, nil
-					}
-				}
-			}
+		historyPath := filepath.Join(opts.LogDir, historyFileName)
+		if err := exportHistory(historyPath, cfg.historyRetention(), *exportFlag, res, from, to, monitor.hostInfo); err != nil {
+			log.Fatalf("Ошибка выгрузки истории: %v", err)
+		}
+		if *exportFlag != "-" {
+			log.Printf("История за %s — %s сохранена в %s", from.Format("2006/01/02 15:04"), to.Format("2006/01/02 15:04"), *exportFlag)
 		}
+		return
 	}
 
-	return "", fmt.Errorf("шлюз по умолчанию не найден")
-}
-
-func main() {
 	// Инициализация кодировки для Windows
 	if runtime.GOOS == "windows" {
 		// Устанавливаем кодировку консоли в UTF-8
@@ -406,22 +582,18 @@ func main() {
 		cmd.Run()
 	}
 
-	// Проверяем доступность необходимых утилит
+	// Утилита ping нужна только как запасной вариант для встроенного пинга
 	if !checkCommandAvailable("ping") {
-		log.Fatal("Утилита ping не найдена в системе")
+		log.Println("Предупреждение: утилита ping не найдена в системе, доступен только встроенный ICMP-пинг")
 	}
 
 	// Создание папки для логов
-	if err := ensureLogDir(); err != nil {
+	if err := ensureLogDir(opts.LogDir); err != nil {
 		log.Fatalf("Ошибка при создании папки для логов: %v", err)
 	}
 
-	// Открываем лог-файл с учетом ОС
-	logDir := "stats_and_graphs"
-	if runtime.GOOS == "windows" {
-		logDir = filepath.Join(".", logDir)
-	}
-	logPath := filepath.Join(logDir, "ping_statistics.log")
+	// Открываем лог-файл в каталоге из конфигурации
+	logPath := filepath.Join(opts.LogDir, "ping_statistics.log")
 	logFile, err := os.OpenFile(logPath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
 		log.Fatalf("Ошибка при открытии лог-файла: %v", err)
@@ -431,15 +603,51 @@ func main() {
 	// Настроим логирование в файл и в консоль
 	log.SetOutput(io.MultiWriter(logFile, os.Stdout))
 	log.Println("Программа для сбора статистики пинга!")
-	log.Println("Лог сохранён в stats_and_graphs/ping_statistics.log")
+	log.Printf("Лог сохранён в %s", logPath)
 	log.Println("Made by Lg$")
 
+	// История измерений хранится между запусками в базе в каталоге логов
+	if cfg.History.Enabled && opts.LogDir != "" {
+		historyPath := filepath.Join(opts.LogDir, historyFileName)
+		history, err := openHistory(historyPath, cfg.historyRetention())
+		if err != nil {
+			log.Printf("Предупреждение: история измерений не ведётся: %v", err)
+			monitor.SetHistoryError(err)
+		} else {
+			defer history.Close()
+			monitor.SetHistory(history)
+			log.Printf("История измерений сохраняется в %s", historyPath)
+		}
+	}
+
+	if cfg.MetricsListen != "" {
+		startMetricsServer(cfg.MetricsListen, monitor)
+	}
+
 	// Собираем информацию о сети
-	networkHosts, err := collectNetworkInfo()
-	if err != nil {
-		log.Printf("Предупреждение: %v", err)
+	var networkHosts []string
+	if cfg.Discover {
+		networkHosts, err = collectNetworkInfo()
+		if err != nil {
+			log.Printf("Предупреждение: %v", err)
+		}
 	}
 
-	// Запускаем GUI с собранными хостами
-	createGUI(networkHosts)
+	// Добавляем хосты из конфигурации и командной строки
+	networkHosts = append(networkHosts, cfg.configHosts()...)
+	networkHosts = append(networkHosts, strings.Split(*hostsFlag, ",")...)
+	monitor.SetHosts(networkHosts)
+
+	if *headless || !guiAvailable {
+		runHeadless(monitor)
+	} else {
+		// Запускаем GUI с собранными хостами. Если окно закрыли во время
+		// сбора или трассировки, останавливаем их и дожидаемся записи итогов.
+		createGUI(monitor, cfg, *configFlag)
+		monitor.StopMTR()
+		monitor.Stop()
+		<-monitor.MTRDone()
+		<-monitor.Done()
+	}
+	log.Println("Завершено выполнение программы.")
 }
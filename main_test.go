@@ -0,0 +1,129 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// mtrRawFixture — вывод mtr --raw --report-cycles 3 до 8.8.8.8
+// (mtr 0.95): третий хоп не отвечает, до второго потерян один зонд
+const mtrRawFixture = `x 0 33000
+h 0 192.168.1.1
+d 0 router.lan
+p 0 1523 33000
+x 1 33001
+h 1 10.20.0.1
+p 1 8532 33001
+x 2 33002
+x 3 33003
+h 3 8.8.8.8
+d 3 dns.google
+p 3 14210 33003
+x 0 33004
+p 0 1311 33004
+x 1 33005
+x 2 33006
+x 3 33007
+p 3 15002 33007
+x 0 33008
+p 0 1702 33008
+x 1 33009
+p 1 9014 33009
+x 2 33010
+x 3 33011
+p 3 13876 33011
+`
+
+func TestParseMTRRawLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantPos int
+		wantOK  bool
+	}{
+		{"отправка", "x 2 33002", 2, true},
+		{"адрес", "h 1 10.20.0.1", 1, true},
+		{"ответ", "p 0 1523 33000", 0, true},
+		{"ответ без seq", "p 0 1523", 0, true},
+		{"имя хопа", "d 0 router.lan", 0, false},
+		{"неизвестная строка", "z 0 1", 0, false},
+		{"короткая строка", "x 0", 0, false},
+		{"пустая строка", "", 0, false},
+		{"хоп вне диапазона", "x 30 33000", 0, false},
+		{"отрицательный хоп", "x -1 33000", 0, false},
+		{"нечисловой хоп", "x a 33000", 0, false},
+		{"нечисловое RTT", "p 0 abc 33000", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hops := make([]WinMTRHop, 4)
+			pos, ok := parseMTRRawLine(tt.line, hops)
+			if pos != tt.wantPos || ok != tt.wantOK {
+				t.Errorf("parseMTRRawLine(%q) = %d, %v; want %d, %v", tt.line, pos, ok, tt.wantPos, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseMTRRawLineFixture(t *testing.T) {
+	hops := make([]WinMTRHop, 30)
+	for _, line := range strings.Split(mtrRawFixture, "\n") {
+		parseMTRRawLine(line, hops)
+	}
+
+	tests := []struct {
+		address        string
+		success        bool
+		sent, received int
+		loss           float64
+		best, worst    time.Duration
+		last           time.Duration
+	}{
+		{"192.168.1.1", true, 3, 3, 0, 1311 * time.Microsecond, 1702 * time.Microsecond, 1702 * time.Microsecond},
+		{"10.20.0.1", true, 3, 2, 100.0 / 3, 8532 * time.Microsecond, 9014 * time.Microsecond, 9014 * time.Microsecond},
+		{"", false, 3, 0, 100, 0, 0, 0},
+		{"8.8.8.8", true, 3, 3, 0, 13876 * time.Microsecond, 15002 * time.Microsecond, 13876 * time.Microsecond},
+	}
+	for i, want := range tests {
+		got := hops[i]
+		if got.Address != want.address || got.Success != want.success {
+			t.Errorf("хоп %d: адрес %q (%v), ожидался %q (%v)", i, got.Address, got.Success, want.address, want.success)
+		}
+		if got.Sent != want.sent || got.Received != want.received {
+			t.Errorf("хоп %d: отправлено/получено %d/%d, ожидалось %d/%d", i, got.Sent, got.Received, want.sent, want.received)
+		}
+		if diff := got.Loss - want.loss; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("хоп %d: потери %.3f%%, ожидалось %.3f%%", i, got.Loss, want.loss)
+		}
+		if got.Best != want.best || got.Worst != want.worst || got.Last != want.last {
+			t.Errorf("хоп %d: лучшее/худшее/последнее %v/%v/%v, ожидалось %v/%v/%v",
+				i, got.Best, got.Worst, got.Last, want.best, want.worst, want.last)
+		}
+	}
+
+	// Среднее и джиттер второго хопа по двум ответам
+	if want := (8532 + 9014) / 2 * time.Microsecond; hops[1].Avg != want {
+		t.Errorf("хоп 1: среднее %v, ожидалось %v", hops[1].Avg, want)
+	}
+	if want := (9014 - 8532) * time.Microsecond; hops[1].Jitter != want {
+		t.Errorf("хоп 1: джиттер %v, ожидалось %v", hops[1].Jitter, want)
+	}
+	for i := 4; i < len(hops); i++ {
+		if hops[i].Sent != 0 {
+			t.Errorf("хоп %d: лишние зонды %d", i, hops[i].Sent)
+		}
+	}
+}
+
+// Старые версии mtr не печатают строки "x": число отправленных
+// зондов берётся по числу ответов
+func TestParseMTRRawLineWithoutSent(t *testing.T) {
+	hops := make([]WinMTRHop, 1)
+	for _, line := range []string{"h 0 192.168.1.1", "p 0 1000", "p 0 3000"} {
+		parseMTRRawLine(line, hops)
+	}
+	if hops[0].Sent != 2 || hops[0].Received != 2 || hops[0].Loss != 0 {
+		t.Errorf("отправлено/получено/потери %d/%d/%.1f, ожидалось 2/2/0", hops[0].Sent, hops[0].Received, hops[0].Loss)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startMetricsServer запускает HTTP-сервер с метриками Prometheus монитора m
+// на /metrics и выгрузкой истории в CSV на /export.csv
+func startMetricsServer(addr string, m *Monitor) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(m))
+	mux.Handle("/export.csv", exportHandler(m))
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		log.Printf("Метрики Prometheus доступны на http://%s/metrics, история в CSV — на http://%s/export.csv", addr, addr)
+		if err := server.ListenAndServe(); err != nil {
+			log.Printf("Ошибка сервера метрик: %v", err)
+		}
+	}()
+}
+
+// metricsHandler отдаёт метрики монитора m в текстовом формате Prometheus
+func metricsHandler(m *Monitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, m.Snapshot(), m.LastMTR())
+	})
+}
+
+// metricsWriter формирует текстовый формат экспозиции Prometheus
+type metricsWriter struct {
+	w io.Writer
+}
+
+// header выводит описание метрики
+func (m metricsWriter) header(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample выводит значение метрики с метками (пары имя, значение)
+func (m metricsWriter) sample(name string, value float64, labels ...string) {
+	io.WriteString(m.w, name)
+	if len(labels) > 0 {
+		io.WriteString(m.w, "{")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				io.WriteString(m.w, ",")
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		io.WriteString(m.w, "}")
+	}
+	fmt.Fprintf(m.w, " %s\n", formatMetricValue(value))
+}
+
+// escapeLabelValue экранирует значение метки по правилам формата Prometheus
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatMetricValue форматирует число для формата Prometheus
+func formatMetricValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// msToSeconds переводит миллисекунды в секунды, в которых Prometheus хранит время
+func msToSeconds(ms float64) float64 {
+	return ms / 1000
+}
+
+// unixSeconds возвращает метку времени в секундах, для нулевого времени — 0
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+// writeMetrics выводит метрики пинга и хопов последней трассировки
+func writeMetrics(w io.Writer, stats []PingStats, lastMTR *mtrSnapshot) {
+	m := metricsWriter{w: w}
+
+	labels := func(s *PingStats) []string {
+		return []string{"host", s.Host, "group", s.Group}
+	}
+	gauges := []struct {
+		name, help string
+		value      func(s *PingStats) float64
+	}{
+		{"pingstats_rtt_min_seconds", "Минимальное RTT за последний цикл.", func(s *PingStats) float64 { return msToSeconds(s.MinRTT) }},
+		{"pingstats_rtt_avg_seconds", "Среднее RTT за последний цикл.", func(s *PingStats) float64 { return msToSeconds(s.AvgRTT) }},
+		{"pingstats_rtt_max_seconds", "Максимальное RTT за последний цикл.", func(s *PingStats) float64 { return msToSeconds(s.MaxRTT) }},
+		{"pingstats_rtt_stddev_seconds", "Стандартное отклонение RTT по окну измерений.", func(s *PingStats) float64 { return msToSeconds(s.StdDev) }},
+		{"pingstats_jitter_seconds", "Джиттер RTT по RFC 3550 по окну измерений.", func(s *PingStats) float64 { return msToSeconds(s.Jitter) }},
+		{"pingstats_loss_ratio", "Доля потерянных пакетов за последний цикл (0-1).", func(s *PingStats) float64 { return s.PacketLoss / 100 }},
+		{"pingstats_last_success_timestamp_seconds", "Время последнего цикла, в котором был получен ответ (0 — ответов не было).", func(s *PingStats) float64 { return unixSeconds(s.LastSuccess) }},
+	}
+	for _, g := range gauges {
+		m.header(g.name, "gauge", g.help)
+		for i := range stats {
+			m.sample(g.name, g.value(&stats[i]), labels(&stats[i])...)
+		}
+	}
+
+	m.header("pingstats_probes_sent_total", "counter", "Отправлено эхо-запросов с начала работы.")
+	for i := range stats {
+		m.sample("pingstats_probes_sent_total", float64(stats[i].TotalSent), labels(&stats[i])...)
+	}
+	m.header("pingstats_probes_received_total", "counter", "Получено ответов с начала работы.")
+	for i := range stats {
+		m.sample("pingstats_probes_received_total", float64(stats[i].TotalReceived), labels(&stats[i])...)
+	}
+
+	m.header("pingstats_probe_failures_total", "counter", "Неудачные попытки с начала работы по причинам: timeout, refused, reset, unreachable, dns, status, body, rcode, cert, error.")
+	for i := range stats {
+		s := &stats[i]
+		reasons := make([]string, 0, len(s.TotalFailures))
+		for reason := range s.TotalFailures {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			m.sample("pingstats_probe_failures_total", float64(s.TotalFailures[reason]), append(labels(s), "reason", reason)...)
+		}
+	}
+
+	m.header("pingstats_rtt_seconds", "histogram", "Распределение RTT полученных ответов с начала работы.")
+	for i := range stats {
+		s := &stats[i]
+		for j, bound := range rttBuckets {
+			count := 0
+			if j < len(s.RTTBuckets) {
+				count = s.RTTBuckets[j]
+			}
+			le := formatMetricValue(msToSeconds(bound))
+			m.sample("pingstats_rtt_seconds_bucket", float64(count), append(labels(s), "le", le)...)
+		}
+		m.sample("pingstats_rtt_seconds_bucket", float64(s.RTTCount), append(labels(s), "le", "+Inf")...)
+		m.sample("pingstats_rtt_seconds_sum", msToSeconds(s.RTTSum), labels(s)...)
+		m.sample("pingstats_rtt_seconds_count", float64(s.RTTCount), labels(s)...)
+	}
+
+	writeHTTPMetrics(m, stats)
+	writeDNSMetrics(m, stats)
+	writeTLSMetrics(m, stats)
+	writeMTRMetrics(m, lastMTR)
+}
+
+// writeTLSMetrics выводит время TLS-рукопожатия и срок действия сертификатов
+func writeTLSMetrics(m metricsWriter, stats []PingStats) {
+	var hosts []*PingStats
+	for i := range stats {
+		if stats[i].TLS != nil && len(stats[i].TLS.Chain) > 0 {
+			hosts = append(hosts, &stats[i])
+		}
+	}
+	if len(hosts) == 0 {
+		return
+	}
+
+	m.header("pingstats_tls_handshake_seconds", "gauge", "Время последнего TLS-рукопожатия.")
+	for _, s := range hosts {
+		m.sample("pingstats_tls_handshake_seconds", msToSeconds(s.TLS.Handshake), "host", s.Host, "group", s.Group)
+	}
+
+	m.header("pingstats_tls_cert_expiry_timestamp_seconds", "gauge", "Окончание срока действия цепочки сертификатов.")
+	for _, s := range hosts {
+		m.sample("pingstats_tls_cert_expiry_timestamp_seconds", unixSeconds(s.TLS.NotAfter), "host", s.Host, "group", s.Group)
+	}
+}
+
+// writeDNSMetrics выводит код и число записей последнего ответа DNS-серверов
+func writeDNSMetrics(m metricsWriter, stats []PingStats) {
+	resolvers := dnsComparison(stats)
+	if len(resolvers) == 0 {
+		return
+	}
+
+	m.header("pingstats_dns_answers", "gauge", "Число записей в последнем ответе DNS-сервера.")
+	for i := range resolvers {
+		s := &resolvers[i]
+		m.sample("pingstats_dns_answers", float64(s.DNS.Answers), "host", s.Host, "group", s.Group)
+	}
+
+	m.header("pingstats_dns_rcode", "gauge", "Код последнего ответа DNS-сервера: 1 для текущего кода.")
+	for i := range resolvers {
+		s := &resolvers[i]
+		if s.DNS.Rcode != "" {
+			m.sample("pingstats_dns_rcode", 1, "host", s.Host, "group", s.Group, "rcode", s.DNS.Rcode)
+		}
+	}
+}
+
+// writeHTTPMetrics выводит этапы HTTP(S)-запросов и коды ответов
+func writeHTTPMetrics(m metricsWriter, stats []PingStats) {
+	var hosts []*PingStats
+	for i := range stats {
+		if stats[i].HTTP != nil {
+			hosts = append(hosts, &stats[i])
+		}
+	}
+	if len(hosts) == 0 {
+		return
+	}
+
+	m.header("pingstats_http_phase_seconds", "gauge", "Длительность этапов HTTP(S)-запроса, среднее за последний цикл.")
+	for _, s := range hosts {
+		phases := []struct {
+			name  string
+			value float64
+		}{
+			{"dns", s.HTTP.DNS},
+			{"connect", s.HTTP.Connect},
+			{"tls", s.HTTP.TLS},
+			{"ttfb", s.HTTP.TTFB},
+			{"total", s.HTTP.Total},
+		}
+		for _, p := range phases {
+			m.sample("pingstats_http_phase_seconds", msToSeconds(p.value), "host", s.Host, "group", s.Group, "phase", p.name)
+		}
+	}
+
+	m.header("pingstats_http_status_code", "gauge", "Код последнего ответа HTTP(S) (0 — ответов не было).")
+	for _, s := range hosts {
+		m.sample("pingstats_http_status_code", float64(s.HTTP.StatusCode), "host", s.Host, "group", s.Group)
+	}
+}
+
+// writeMTRMetrics выводит метрики по хопам последней трассировки
+func writeMTRMetrics(m metricsWriter, snapshot *mtrSnapshot) {
+	if snapshot == nil {
+		return
+	}
+
+	m.header("pingstats_mtr_last_run_timestamp_seconds", "gauge", "Время последнего обновления трассировки.")
+	m.sample("pingstats_mtr_last_run_timestamp_seconds", unixSeconds(snapshot.Time), "target", snapshot.Target)
+
+	labels := func(h *WinMTRHop) []string {
+		return []string{"target", snapshot.Target, "hop", strconv.Itoa(h.Hop), "address", h.Address}
+	}
+	gauges := []struct {
+		name, help string
+		value      func(h *WinMTRHop) float64
+	}{
+		{"pingstats_mtr_hop_loss_ratio", "Доля потерь на хопе (0-1).", func(h *WinMTRHop) float64 { return h.Loss / 100 }},
+		{"pingstats_mtr_hop_sent", "Отправлено зондов на хоп за трассировку.", func(h *WinMTRHop) float64 { return float64(h.Sent) }},
+		{"pingstats_mtr_hop_received", "Получено ответов от хопа за трассировку.", func(h *WinMTRHop) float64 { return float64(h.Received) }},
+		{"pingstats_mtr_hop_rtt_last_seconds", "RTT последнего ответа хопа.", func(h *WinMTRHop) float64 { return h.Last.Seconds() }},
+		{"pingstats_mtr_hop_rtt_avg_seconds", "Среднее RTT хопа.", func(h *WinMTRHop) float64 { return h.Avg.Seconds() }},
+		{"pingstats_mtr_hop_rtt_best_seconds", "Лучшее RTT хопа.", func(h *WinMTRHop) float64 { return h.Best.Seconds() }},
+		{"pingstats_mtr_hop_rtt_worst_seconds", "Худшее RTT хопа.", func(h *WinMTRHop) float64 { return h.Worst.Seconds() }},
+		{"pingstats_mtr_hop_rtt_stddev_seconds", "Стандартное отклонение RTT хопа.", func(h *WinMTRHop) float64 { return h.StdDev.Seconds() }},
+		{"pingstats_mtr_hop_jitter_seconds", "Средняя разница RTT соседних ответов хопа.", func(h *WinMTRHop) float64 { return h.Jitter.Seconds() }},
+	}
+	for _, g := range gauges {
+		m.header(g.name, "gauge", g.help)
+		for i := range snapshot.Hops {
+			m.sample(g.name, g.value(&snapshot.Hops[i]), labels(&snapshot.Hops[i])...)
+		}
+	}
+}
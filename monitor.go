@@ -0,0 +1,660 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MonitorOptions — параметры сбора статистики
+type MonitorOptions struct {
+	Interval time.Duration // Интервал между циклами пинга
+	End      time.Time     // Окончание сбора; нулевое время — до вызова Stop
+	Probe    ProbeOptions
+	Window   int    // Сколько последних RTT хранится по каждому хосту
+	LogDir   string // Каталог для логов; пустой — логи в файлы не пишутся
+
+	LogFormats []string // Форматы логов результатов (text, jsonl, csv); пусто — только текст
+}
+
+// mtrSnapshot — статистика по хопам последней трассировки
+type mtrSnapshot struct {
+	Target string
+	Hops   []WinMTRHop
+	Time   time.Time
+}
+
+// errMonitorRunning возвращается при повторном запуске сбора статистики
+var errMonitorRunning = errors.New("сбор статистики уже запущен")
+
+// errMTRRunning возвращается при запуске второй трассировки
+var errMTRRunning = errors.New("трассировка уже запущена")
+
+// Monitor периодически пингует список хостов, хранит статистику по ним
+// и пишет логи. GUI, режим без GUI и сервер метрик работают через него.
+type Monitor struct {
+	mu      sync.RWMutex
+	opts    MonitorOptions
+	hosts   []string
+	info    map[string]hostInfo // Подписи, группы и способ пинга из конфигурации
+	stats   map[string]*PingStats
+	lastMTR *mtrSnapshot
+	subs    map[chan []PingStats]struct{}
+	history *historyStore // История измерений; nil — история не ведётся
+	histErr error         // Почему история включена, но не ведётся
+	sinks   *logSinks     // Машиночитаемые логи
+
+	// Результаты циклов текущей сессии по хостам: по ним строятся графики
+	// и периоды недоступности в отчёте, если история не ведётся
+	points map[string][]historySample
+
+	cancel  context.CancelFunc // Остановка текущего сбора, nil если не запущен
+	done    chan struct{}      // Закрывается после остановки сбора и записи итогов
+	started time.Time          // Начало текущего или последнего сбора
+
+	mtrCancel context.CancelFunc // Остановка текущей трассировки, nil если не запущена
+	mtrDone   chan struct{}      // Закрывается после записи результатов трассировки
+}
+
+// NewMonitor создаёт монитор с указанными параметрами
+func NewMonitor(opts MonitorOptions) *Monitor {
+	done := make(chan struct{})
+	close(done)
+	return &Monitor{
+		opts:    opts,
+		info:    make(map[string]hostInfo),
+		stats:   make(map[string]*PingStats),
+		points:  make(map[string][]historySample),
+		subs:    make(map[chan []PingStats]struct{}),
+		sinks:   newLogSinks(),
+		done:    done,
+		mtrDone: done,
+	}
+}
+
+// Options возвращает текущие параметры сбора
+func (m *Monitor) Options() MonitorOptions {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.opts
+}
+
+// SetOptions меняет параметры сбора. Запущенный сбор подхватывает параметры
+// пинга со следующего цикла, интервал и время окончания — при следующем запуске.
+func (m *Monitor) SetOptions(opts MonitorOptions) {
+	m.mu.Lock()
+	m.opts = opts
+	m.mu.Unlock()
+}
+
+// SetHosts задаёт список хостов для пинга
+func (m *Monitor) SetHosts(hosts []string) {
+	m.mu.Lock()
+	m.hosts = uniqueHosts(hosts)
+	m.mu.Unlock()
+}
+
+// Hosts возвращает список хостов для пинга
+func (m *Monitor) Hosts() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.hosts...)
+}
+
+// SetHostInfo задаёт сведения о хостах из конфигурации
+func (m *Monitor) SetHostInfo(info map[string]hostInfo) {
+	m.mu.Lock()
+	m.info = info
+	m.mu.Unlock()
+}
+
+// SetHistory задаёт базу, в которую записываются результаты каждого цикла
+func (m *Monitor) SetHistory(history *historyStore) {
+	m.mu.Lock()
+	m.history = history
+	m.mu.Unlock()
+}
+
+// SetHistoryError запоминает ошибку открытия базы истории, чтобы отчёт
+// отличал недоступную историю от отключённой в конфигурации
+func (m *Monitor) SetHistoryError(err error) {
+	m.mu.Lock()
+	m.histErr = err
+	m.mu.Unlock()
+}
+
+// History возвращает базу истории или nil, если история не ведётся
+func (m *Monitor) History() *historyStore {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.history
+}
+
+// hostInfo возвращает сведения о хосте из конфигурации
+func (m *Monitor) hostInfo(host string) hostInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.hostInfoLocked(host)
+}
+
+// hostInfoLocked возвращает сведения о хосте; вызывается под m.mu.
+// Запись с суффиксами семейства и интерфейса, которой нет в конфигурации,
+// наследует сведения исходной записи, а к подписи добавляются суффиксы.
+func (m *Monitor) hostInfoLocked(host string) hostInfo {
+	if info, ok := m.info[host]; ok {
+		return info
+	}
+	base, family, iface := splitHostKey(host)
+	for _, c := range []struct{ key, family, iface string }{
+		{withFamily(base, family), "", iface},
+		{withInterface(base, iface), family, ""},
+		{base, family, iface},
+	} {
+		if info, ok := m.info[c.key]; ok {
+			if info.Label != "" {
+				info.Label = withInterface(withFamily(info.Label, c.family), c.iface)
+			}
+			return info
+		}
+	}
+	return hostInfo{}
+}
+
+// Start запускает периодический сбор статистики в отдельной горутине.
+// Возвращает errMonitorRunning, если сбор уже идёт.
+func (m *Monitor) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		return errMonitorRunning
+	}
+
+	// Каталог логов создаётся один раз на сбор, а не в каждом цикле
+	if err := ensureLogDir(m.opts.LogDir); err != nil {
+		log.Printf("Ошибка при создании каталога для логов: %v", err)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if m.opts.End.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), m.opts.End)
+	}
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	m.started = time.Now()
+	// Итоги сессии считаются с начала этого сбора: статистика предыдущего
+	// сбора в GUI (Стоп → Старт) в них не попадает
+	m.stats = make(map[string]*PingStats)
+	m.points = make(map[string][]historySample)
+	go m.run(ctx, m.opts.Interval, m.done)
+	return nil
+}
+
+// Stop останавливает сбор статистики. Текущий цикл пинга доводится до конца,
+// затем записывается итоговая статистика и закрывается канал Done.
+func (m *Monitor) Stop() {
+	m.mu.RLock()
+	cancel := m.cancel
+	m.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Done возвращает канал, который закрывается по окончании текущего сбора
+func (m *Monitor) Done() <-chan struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.done
+}
+
+// Running сообщает, идёт ли сбор статистики
+func (m *Monitor) Running() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cancel != nil
+}
+
+// run выполняет циклы пинга с интервалом interval до отмены ctx
+func (m *Monitor) run(ctx context.Context, interval time.Duration, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		// Итоги записываются, пока сбор ещё считается запущенным: Start до
+		// этого отказывает и не подменяет статистику и начало сессии
+		if err := m.SaveFinalStats(); err != nil {
+			log.Printf("Ошибка при обновлении каталога логов: %v", err)
+		}
+
+		m.mu.Lock()
+		m.cancel()
+		m.cancel = nil
+		m.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Устаревшая история удаляется при запуске и затем раз в час
+	m.pruneHistory()
+	pruneTicker := time.NewTicker(time.Hour)
+	defer pruneTicker.Stop()
+
+	// Остановка не прерывает начатый цикл, чтобы незавершённые
+	// проверки не посчитались потерями
+	cycleCtx := context.WithoutCancel(ctx)
+
+	// Сразу запускаем первый сбор статистики
+	m.Collect(cycleCtx)
+	for {
+		select {
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				return
+			}
+			m.Collect(cycleCtx)
+		case <-pruneTicker.C:
+			m.pruneHistory()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Collect выполняет один цикл проверки всех хостов и уведомляет подписчиков.
+// Отмена ctx прерывает незавершённые проверки.
+func (m *Monitor) Collect(ctx context.Context) {
+	hosts := m.Hosts()
+	if len(hosts) == 0 {
+		log.Println("Не указаны хосты для пинга")
+		return
+	}
+	opts := m.Options()
+
+	// Канал для сбора результатов пинга
+	results := make(chan string, len(hosts))
+	var wg sync.WaitGroup
+
+	// Пинг каждого хоста параллельно
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			results <- m.probeVariants(ctx, host, opts.Probe)
+		}(host)
+	}
+
+	// Запускаем горутину для закрытия канала после завершения всех пингов
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Читаем результаты из канала
+	for result := range results {
+		log.Println(result)
+	}
+
+	log.Println("------------")
+
+	m.publish()
+}
+
+// probeVariants проверяет все варианты записи хоста (см. hostVariants)
+// параллельно; статистика по каждому варианту ведётся отдельно
+func (m *Monitor) probeVariants(ctx context.Context, host string, opts ProbeOptions) string {
+	hosts := m.hostVariants(ctx, host, opts)
+	if len(hosts) == 1 {
+		return m.probeHost(ctx, hosts[0], opts)
+	}
+
+	outputs := make([]string, len(hosts))
+	var wg sync.WaitGroup
+	for i, h := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outputs[i] = m.probeHost(ctx, h, opts)
+		}()
+	}
+	wg.Wait()
+	return strings.Join(outputs, "\n")
+}
+
+// hostVariants возвращает записи, по которым проверяется хост: по одной на
+// каждый интерфейс из opts.Interfaces (если интерфейс не указан в самой
+// записи), а для имён с адресами IPv4 и IPv6 при opts.DualStack — ещё и
+// по каждому семейству (см. dualStackHosts)
+func (m *Monitor) hostVariants(ctx context.Context, host string, opts ProbeOptions) []string {
+	hosts := []string{host}
+	if _, iface := splitInterface(host); iface == "" && len(opts.Interfaces) > 0 {
+		hosts = hosts[:0]
+		for _, iface := range opts.Interfaces {
+			hosts = append(hosts, withInterface(host, iface))
+		}
+	}
+	if !opts.DualStack {
+		return hosts
+	}
+
+	var variants []string
+	for _, h := range hosts {
+		target, err := m.target(h, opts)
+		if err != nil {
+			variants = append(variants, h)
+			continue
+		}
+		lookupCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		variants = append(variants, dualStackHosts(lookupCtx, target)...)
+		cancel()
+	}
+	return variants
+}
+
+// probeHost проверяет хост способом из его записи, сохраняет статистику и
+// возвращает текст результата для лога
+func (m *Monitor) probeHost(ctx context.Context, host string, opts ProbeOptions) string {
+	report, err := probeTarget(ctx, host, m.hostInfo(host), opts)
+	if err != nil {
+		// Проверку не удалось выполнить: считаем все попытки потерянными
+		results := lostResults(opts.Count)
+		for i := range results {
+			results[i].Fail = classifyError(err)
+		}
+		m.Record(statsFromResults(host, results))
+		return fmt.Sprintf("Ошибка при проверке %s: %v", host, err)
+	}
+
+	stats := statsFromResults(host, report.Results)
+	stats.HTTP, stats.DNS, stats.TLS = report.HTTP, report.DNS, report.TLS
+	stats.Prober = report.Prober
+	m.Record(stats)
+	output := report.Output
+	if output == "" {
+		output = formatPingResults(report.Results, stats)
+	}
+	if report.HTTP != nil {
+		output += formatHTTPStats(report.HTTP)
+	}
+	if report.DNS != nil {
+		output += formatDNSStats(report.DNS)
+	}
+	if report.TLS != nil {
+		output += formatTLSStats(report.TLS)
+	}
+	return fmt.Sprintf("Результаты пинга для %s (%s):\n%s", host, report.Mode, output)
+}
+
+// target разбирает запись хоста со способом проверки из конфигурации
+func (m *Monitor) target(host string, opts ProbeOptions) (Target, error) {
+	return hostTarget(host, m.hostInfo(host), opts)
+}
+
+// hostTarget разбирает запись хоста. Способ проверки адреса без схемы
+// берётся из info.Probe или opts.Backend.
+func hostTarget(host string, info hostInfo, opts ProbeOptions) (Target, error) {
+	scheme := opts.Backend
+	if info.Probe != "" {
+		scheme = info.Probe
+	}
+	target, err := parseTarget(host, scheme)
+	if err != nil {
+		return Target{}, err
+	}
+	target.HTTP = info.HTTP
+	return target, nil
+}
+
+// probeTarget разбирает запись хоста и проверяет его подходящим Prober
+func probeTarget(ctx context.Context, host string, info hostInfo, opts ProbeOptions) (ProbeReport, error) {
+	target, err := hostTarget(host, info, opts)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+	prober, ok := probers[target.Scheme]
+	if !ok {
+		return ProbeReport{}, fmt.Errorf("неизвестный способ проверки %q (допустимо: %s)", target.Scheme, proberSchemes())
+	}
+	report, err := prober.Probe(ctx, target, opts)
+	report.Prober = target.Scheme
+	return report, err
+}
+
+// Record добавляет результаты цикла пинга к статистике хоста
+func (m *Monitor) Record(stats *PingStats) {
+	m.mu.Lock()
+	info := m.hostInfoLocked(stats.Host)
+	stats.Label, stats.Group = info.Label, info.Group
+
+	// Добавляем новые RTT к окну предыдущих измерений
+	// и к накопленным показателям сессии
+	prev := m.stats[stats.Host]
+	var prevSamples []float64
+	if prev != nil {
+		prevSamples = prev.Samples
+	}
+	cycleSamples := stats.Samples
+	stats.Samples = mergeSamples(prevSamples, cycleSamples, m.opts.Window)
+	updateWindowStats(stats)
+	mergeSession(prev, stats, cycleSamples)
+	m.stats[stats.Host] = stats
+	sample := historySampleFromStats(stats)
+	m.points[stats.Host] = appendSessionPoint(m.points[stats.Host], sample)
+	logDir, formats := m.opts.LogDir, m.opts.LogFormats
+	history := m.history
+	m.mu.Unlock()
+
+	// Обновляем статистику в логах и в истории
+	if err := m.sinks.writePingRecord(logDir, formats, stats, cycleSamples); err != nil {
+		log.Printf("Ошибка при обновлении файла статистики: %v", err)
+	}
+	if history != nil {
+		if err := history.Add(stats.Host, sample); err != nil {
+			log.Printf("Ошибка при обновлении истории: %v", err)
+		}
+	}
+}
+
+// pruneHistory удаляет из истории записи старше срока хранения
+func (m *Monitor) pruneHistory() {
+	if history := m.History(); history != nil {
+		if err := history.Prune(time.Now()); err != nil {
+			log.Printf("Ошибка при очистке истории: %v", err)
+		}
+	}
+}
+
+// Snapshot возвращает копию статистики всех хостов, упорядоченную по адресу
+func (m *Monitor) Snapshot() []PingStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshotLocked()
+}
+
+// snapshotLocked возвращает копию статистики всех хостов; вызывается под m.mu
+func (m *Monitor) snapshotLocked() []PingStats {
+	snapshot := make([]PingStats, 0, len(m.stats))
+	for _, stats := range m.stats {
+		s := *stats
+		s.Samples = append([]float64(nil), stats.Samples...)
+		s.RTTBuckets = append([]int(nil), stats.RTTBuckets...)
+		s.Failures = maps.Clone(stats.Failures)
+		s.TotalFailures = maps.Clone(stats.TotalFailures)
+		snapshot = append(snapshot, s)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Host < snapshot[j].Host })
+	return snapshot
+}
+
+// Subscribe возвращает канал, в который после каждого цикла пинга приходит
+// снимок статистики. Если подписчик не успевает читать, устаревший снимок
+// заменяется свежим. Вызов возвращённой функции отменяет подписку и
+// закрывает канал.
+func (m *Monitor) Subscribe() (<-chan []PingStats, func()) {
+	ch := make(chan []PingStats, 1)
+	m.mu.Lock()
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subs, ch)
+			close(ch)
+			m.mu.Unlock()
+		})
+	}
+}
+
+// publish рассылает свежий снимок статистики подписчикам
+func (m *Monitor) publish() {
+	snapshot := m.Snapshot()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for ch := range m.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- snapshot:
+		default:
+		}
+	}
+}
+
+// SaveFinalStats записывает итоговую статистику в каталог логов, строит
+// графики RTT и потерь за сессию и сохраняет HTML-отчёт
+func (m *Monitor) SaveFinalStats() error {
+	// Статистика, начало и результаты сессии берутся вместе, чтобы итоги
+	// относились к одной сессии
+	m.mu.RLock()
+	logDir := m.opts.LogDir
+	snapshot := m.snapshotLocked()
+	from := m.started
+	history, historyErr := m.history, m.histErr
+	series := memorySeries(m.points, snapshot)
+	m.mu.RUnlock()
+	to := time.Now()
+
+	if err := ensureLogDir(logDir); err != nil {
+		return err
+	}
+	if err := updateLogDir(logDir, snapshot); err != nil {
+		return err
+	}
+	if logDir == "" {
+		return nil
+	}
+
+	// Без истории графики строятся по результатам циклов в памяти
+	if history != nil {
+		var err error
+		if series, err = sessionSeries(history, snapshot, from, to); err != nil {
+			return err
+		}
+	}
+	if err := writeGraphs(logDir, series, from, to); err != nil {
+		return err
+	}
+	return writeHTMLReport(logDir, snapshot, series, history != nil, historyErr, from, to)
+}
+
+// RunMTR трассирует маршрут до host, пока не будет отменён ctx (см. runMTR).
+// Статистика по хопам доступна через LastMTR, итоговый отчёт пишется в логи.
+func (m *Monitor) RunMTR(ctx context.Context, host string, maxHops int, update func(string)) (string, error) {
+	var lastHops []WinMTRHop
+	output, err := runMTR(ctx, host, maxHops, func(report string, hops []WinMTRHop) {
+		if hops != nil {
+			m.setLastMTR(host, hops)
+			lastHops = append(lastHops[:0], hops...)
+		}
+		update(report)
+	})
+
+	// Сохраняем результаты, в том числе частичные после остановки
+	opts := m.Options()
+	if err := ensureLogDir(opts.LogDir); err != nil {
+		log.Printf("Ошибка при создании каталога для логов: %v", err)
+	} else if err := m.sinks.writeMTRRecord(opts.LogDir, opts.LogFormats, host, output, lastHops); err != nil {
+		log.Printf("Ошибка при обновлении файла логов MTR: %v", err)
+	}
+	return output, err
+}
+
+// StartMTR запускает в фоне трассировку до host (см. RunMTR), которая идёт до
+// вызова StopMTR. update получает отчёт после каждого раунда, finish — итоговый
+// отчёт и ошибку, когда результаты уже записаны в логи.
+func (m *Monitor) StartMTR(host string, maxHops int, update func(string), finish func(string, error)) error {
+	m.mu.Lock()
+	if m.mtrCancel != nil {
+		m.mu.Unlock()
+		return errMTRRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	m.mtrCancel, m.mtrDone = cancel, done
+	m.mu.Unlock()
+
+	go func() {
+		output, err := m.RunMTR(ctx, host, maxHops, update)
+		cancel()
+		m.mu.Lock()
+		m.mtrCancel = nil
+		m.mu.Unlock()
+		close(done)
+		finish(output, err)
+	}()
+	return nil
+}
+
+// StopMTR останавливает трассировку, запущенную StartMTR. Частичные
+// результаты записываются в логи до закрытия канала MTRDone.
+func (m *Monitor) StopMTR() {
+	m.mu.RLock()
+	cancel := m.mtrCancel
+	m.mu.RUnlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// MTRDone возвращает канал, который закрывается по окончании текущей трассировки
+func (m *Monitor) MTRDone() <-chan struct{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mtrDone
+}
+
+// MTRRunning сообщает, идёт ли трассировка, запущенная StartMTR
+func (m *Monitor) MTRRunning() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.mtrCancel != nil
+}
+
+// setLastMTR запоминает статистику трассировки до target
+func (m *Monitor) setLastMTR(target string, hops []WinMTRHop) {
+	snapshot := &mtrSnapshot{Target: target, Hops: make([]WinMTRHop, len(hops)), Time: time.Now()}
+	copy(snapshot.Hops, hops)
+
+	m.mu.Lock()
+	m.lastMTR = snapshot
+	m.mu.Unlock()
+}
+
+// LastMTR возвращает статистику последней трассировки или nil
+func (m *Monitor) LastMTR() *mtrSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lastMTR
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScheme — способ проверки, подменяемый в тестах монитора
+const fakeScheme = "fake"
+
+// fakeProber возвращает заранее заданные RTT по каждому хосту: 0 — потерянный
+// пакет. Для хоста без ответов возвращается ошибка.
+type fakeProber struct {
+	mu    sync.Mutex
+	rtts  map[string][]time.Duration
+	calls map[string]int
+}
+
+func (p *fakeProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls[t.Raw]++
+	rtts, ok := p.rtts[t.Host]
+	if !ok {
+		return ProbeReport{}, context.DeadlineExceeded
+	}
+	results := make([]pingResult, len(rtts))
+	for i, rtt := range rtts {
+		results[i] = pingResult{Seq: i + 1, RTT: rtt, Received: rtt > 0}
+		if rtt == 0 {
+			results[i].Fail = failTimeout
+		}
+	}
+	return ProbeReport{Results: results, Mode: "тест"}, nil
+}
+
+// newFakeMonitor создаёт монитор, проверяющий хосты без схемы через fakeProber
+func newFakeMonitor(t *testing.T, rtts map[string][]time.Duration) (*Monitor, *fakeProber) {
+	t.Helper()
+	prober := &fakeProber{rtts: rtts, calls: make(map[string]int)}
+	probers[fakeScheme] = prober
+	t.Cleanup(func() { delete(probers, fakeScheme) })
+
+	// Результаты циклов пишутся в лог, в выводе тестов они не нужны
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	m := NewMonitor(MonitorOptions{
+		Interval: time.Hour,
+		Window:   5,
+		Probe:    ProbeOptions{Backend: fakeScheme, Count: 4, Timeout: time.Second},
+	})
+	return m, prober
+}
+
+func ms(v float64) time.Duration {
+	return time.Duration(v * float64(time.Millisecond))
+}
+
+func TestMonitorRecordAndSnapshot(t *testing.T) {
+	m, _ := newFakeMonitor(t, nil)
+	m.SetHostInfo(map[string]hostInfo{"8.8.8.8": {Label: "Google", Group: "DNS"}})
+
+	m.Record(statsFromResults("8.8.8.8", []pingResult{
+		{Seq: 1, RTT: ms(10), Received: true},
+		{Seq: 2, RTT: ms(20), Received: true},
+		{Seq: 3, RTT: ms(30), Received: true},
+		{Seq: 4, Fail: failTimeout},
+	}))
+	m.Record(statsFromResults("8.8.8.8", []pingResult{
+		{Seq: 1, RTT: ms(40), Received: true},
+		{Seq: 2, RTT: ms(50), Received: true},
+		{Seq: 3, RTT: ms(60), Received: true},
+		{Seq: 4, RTT: ms(70), Received: true},
+	}))
+	m.Record(statsFromResults("1.1.1.1", lostResults(2)))
+
+	snapshot := m.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Host != "1.1.1.1" || snapshot[1].Host != "8.8.8.8" {
+		t.Fatalf("снимок не упорядочен по адресу: %+v", snapshot)
+	}
+	s := snapshot[1]
+	if s.Label != "Google" || s.Group != "DNS" {
+		t.Errorf("подпись и группа %q/%q, ожидалось Google/DNS", s.Label, s.Group)
+	}
+	if s.TotalSent != 8 || s.TotalReceived != 7 || s.TotalFailures[failTimeout] != 1 {
+		t.Errorf("за сессию %d/%d, таймаутов %d; ожидалось 8/7, 1", s.TotalSent, s.TotalReceived, s.TotalFailures[failTimeout])
+	}
+	if s.LifetimeMin != 10 || s.LifetimeMax != 70 || s.RunningMean != 40 {
+		t.Errorf("мин/сред/макс %v/%v/%v, ожидалось 10/40/70", s.LifetimeMin, s.RunningMean, s.LifetimeMax)
+	}
+	// Окно хранит Window последних RTT
+	want := []float64{30, 40, 50, 60, 70}
+	if len(s.Samples) != len(want) {
+		t.Fatalf("окно RTT %v, ожидалось %v", s.Samples, want)
+	}
+	for i := range want {
+		if s.Samples[i] != want[i] {
+			t.Fatalf("окно RTT %v, ожидалось %v", s.Samples, want)
+		}
+	}
+	if s.P50 != 50 || s.P95 != 70 {
+		t.Errorf("P50/P95 %v/%v, ожидалось 50/70", s.P50, s.P95)
+	}
+	if lost := snapshot[0]; lost.TotalSent != 2 || lost.TotalReceived != 0 || lost.TotalLoss != 100 {
+		t.Errorf("недоступный хост: %d/%d, потери %.0f%%", lost.TotalSent, lost.TotalReceived, lost.TotalLoss)
+	}
+
+	// Снимок — копия: его изменение не затрагивает монитор
+	snapshot[1].Samples[0] = -1
+	snapshot[1].TotalFailures[failTimeout] = 100
+	again := m.Snapshot()[1]
+	if again.Samples[0] != 30 || again.TotalFailures[failTimeout] != 1 {
+		t.Error("изменение снимка затронуло статистику монитора")
+	}
+}
+
+func TestMonitorRecordInheritsHostInfo(t *testing.T) {
+	m, _ := newFakeMonitor(t, nil)
+	m.SetHostInfo(map[string]hostInfo{"ya.ru": {Label: "Яндекс", Group: "Сайты"}})
+
+	host := withInterface(withFamily("ya.ru", familyIPv6), "wg0")
+	m.Record(statsFromResults(host, lostResults(1)))
+	s := m.Snapshot()[0]
+	if want := withInterface(withFamily("Яндекс", familyIPv6), "wg0"); s.Label != want || s.Group != "Сайты" {
+		t.Errorf("подпись и группа %q/%q, ожидалось %q/Сайты", s.Label, s.Group, want)
+	}
+}
+
+func TestMonitorCollectPublishes(t *testing.T) {
+	m, prober := newFakeMonitor(t, map[string][]time.Duration{
+		"8.8.8.8": {ms(5), ms(7), 0, ms(9)},
+	})
+	m.SetHosts([]string{"8.8.8.8", "down.test", "8.8.8.8"})
+
+	updates, unsubscribe := m.Subscribe()
+	m.Collect(context.Background())
+
+	var snapshot []PingStats
+	select {
+	case snapshot = <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("подписчик не получил снимок после цикла")
+	}
+	if len(snapshot) != 2 {
+		t.Fatalf("в снимке %d хостов, ожидалось 2: %+v", len(snapshot), snapshot)
+	}
+	if calls := prober.calls["8.8.8.8"]; calls != 1 {
+		t.Errorf("повторяющийся хост проверен %d раз", calls)
+	}
+
+	up, down := snapshot[0], snapshot[1]
+	if up.Host != "8.8.8.8" || up.Sent != 4 || up.Received != 3 || up.MinRTT != 5 || up.MaxRTT != 9 {
+		t.Errorf("8.8.8.8: %+v", up)
+	}
+	if up.Prober != fakeScheme {
+		t.Errorf("способ проверки %q, ожидалось %q", up.Prober, fakeScheme)
+	}
+	// Ошибка проверки — все попытки считаются потерянными
+	if down.Host != "down.test" || down.Sent != 4 || down.Received != 0 || down.Failures[failTimeout] != 4 {
+		t.Errorf("down.test: %+v", down)
+	}
+
+	// Непрочитанный снимок заменяется свежим
+	m.Collect(context.Background())
+	m.Collect(context.Background())
+	select {
+	case snapshot = <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("подписчик не получил снимок")
+	}
+	if snapshot[0].TotalSent != 12 {
+		t.Errorf("получен устаревший снимок: отправлено %d, ожидалось 12", snapshot[0].TotalSent)
+	}
+	select {
+	case <-updates:
+		t.Error("в канале остался второй снимок")
+	default:
+	}
+
+	// После отписки канал закрыт, повторная отписка безопасна
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-updates; ok {
+		t.Error("канал не закрыт после отписки")
+	}
+	m.Collect(context.Background())
+}
+
+func TestMonitorStartStop(t *testing.T) {
+	m, _ := newFakeMonitor(t, map[string][]time.Duration{"8.8.8.8": {ms(1)}})
+	m.SetHosts([]string{"8.8.8.8"})
+
+	updates, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(); !errors.Is(err, errMonitorRunning) {
+		t.Errorf("повторный запуск: %v, ожидалось %v", err, errMonitorRunning)
+	}
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("первый цикл не выполнен сразу после запуска")
+	}
+
+	m.Stop()
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Fatal("сбор не остановлен")
+	}
+	if m.Running() {
+		t.Error("монитор считается запущенным после остановки")
+	}
+
+	// Время окончания останавливает сбор без Stop
+	opts := m.Options()
+	opts.End = time.Now().Add(50 * time.Millisecond)
+	m.SetOptions(opts)
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Fatal("сбор не остановлен по времени окончания")
+	}
+}
+
+// Новый сбор начинает статистику сессии заново: итоги и отчёт после
+// Стоп → Старт не включают предыдущий сбор
+func TestMonitorStartResetsSession(t *testing.T) {
+	m, _ := newFakeMonitor(t, map[string][]time.Duration{"8.8.8.8": {ms(1), ms(2)}})
+	m.SetHosts([]string{"8.8.8.8"})
+	m.Record(statsFromResults("1.1.1.1", lostResults(4)))
+	m.Record(statsFromResults("8.8.8.8", lostResults(4)))
+
+	updates, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var snapshot []PingStats
+	select {
+	case snapshot = <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("первый цикл не выполнен")
+	}
+	m.Stop()
+	<-m.Done()
+
+	if len(snapshot) != 1 || snapshot[0].Host != "8.8.8.8" {
+		t.Fatalf("в снимке остались хосты прошлого сбора: %+v", snapshot)
+	}
+	if s := snapshot[0]; s.TotalSent != 2 || s.TotalReceived != 2 || s.TotalLoss != 0 {
+		t.Errorf("за сессию %d/%d, потери %.0f%%; ожидалось 2/2 без потерь", s.TotalSent, s.TotalReceived, s.TotalLoss)
+	}
+}
+
+// Быстрый Стоп → Старт не запускает новый сбор, пока итоги прошлого не
+// записаны: иначе в них попала бы статистика новой, пустой сессии
+func TestMonitorRestartWaitsForFinalStats(t *testing.T) {
+	m, _ := newFakeMonitor(t, map[string][]time.Duration{"8.8.8.8": {ms(1)}})
+	m.SetHosts([]string{"8.8.8.8"})
+	opts := m.Options()
+	opts.LogDir = t.TempDir()
+	m.SetOptions(opts)
+
+	updates, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("первый цикл не выполнен")
+	}
+	done := m.Done()
+	m.Stop()
+	for {
+		err := m.Start()
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errMonitorRunning) {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatal("новый сбор запущен до записи итогов прошлого")
+	}
+	data, err := os.ReadFile(filepath.Join(opts.LogDir, "final_statistics.log"))
+	if err != nil || !strings.Contains(string(data), "8.8.8.8") {
+		t.Errorf("итоги прошлого сбора без хоста: %v\n%s", err, data)
+	}
+	m.Stop()
+	<-m.Done()
+}
+
+// Без истории итоги сессии содержат графики по результатам циклов в памяти
+func TestMonitorGraphsWithoutHistory(t *testing.T) {
+	m, _ := newFakeMonitor(t, map[string][]time.Duration{"8.8.8.8": {ms(1), 0}})
+	m.SetHosts([]string{"8.8.8.8"})
+	opts := m.Options()
+	opts.LogDir = t.TempDir()
+	m.SetOptions(opts)
+	// Результаты до запуска к сессии не относятся
+	m.Record(statsFromResults("1.1.1.1", lostResults(4)))
+
+	updates, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("первый цикл не выполнен")
+	}
+	m.Stop()
+	<-m.Done()
+
+	m.mu.RLock()
+	points := m.points
+	m.mu.RUnlock()
+	if len(points) != 1 || len(points["8.8.8.8"]) != 1 || points["8.8.8.8"][0].Sent != 2 || points["8.8.8.8"][0].Received != 1 {
+		t.Errorf("результаты сессии в памяти: %+v", points)
+	}
+	for _, name := range []string{"overview.png", "overview.svg", "host_8.8.8.8.png", "host_8.8.8.8.svg"} {
+		if _, err := os.Stat(filepath.Join(opts.LogDir, graphsDirName, name)); err != nil {
+			t.Errorf("график не построен: %v", err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(opts.LogDir, reportFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := string(data); !strings.Contains(report, "<svg") || !strings.Contains(report, "сохранённым в памяти") || strings.Contains(report, "1.1.1.1") {
+		t.Errorf("отчёт без истории:\n%s", report)
+	}
+}
+
+func TestMonitorMTRStartStop(t *testing.T) {
+	if tracer, err := newMTRTracer("127.0.0.1", 1, time.Second); err != nil {
+		t.Skipf("ICMP-сокет недоступен: %v", err)
+	} else {
+		tracer.Close()
+	}
+	m, _ := newFakeMonitor(t, nil)
+	opts := m.Options()
+	opts.LogDir = t.TempDir()
+	m.SetOptions(opts)
+
+	select {
+	case <-m.MTRDone():
+	default:
+		t.Fatal("канал окончания трассировки не закрыт до запуска")
+	}
+
+	rounds := make(chan string, 100)
+	finished := make(chan error, 1)
+	err := m.StartMTR("127.0.0.1", 4, func(report string) { rounds <- report }, func(_ string, err error) { finished <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.StartMTR("127.0.0.1", 4, func(string) {}, func(string, error) {}); !errors.Is(err, errMTRRunning) {
+		t.Errorf("вторая трассировка: %v, ожидалось %v", err, errMTRRunning)
+	}
+	if !m.MTRRunning() {
+		t.Error("трассировка не считается запущенной")
+	}
+	select {
+	case <-rounds:
+	case <-time.After(3 * time.Second):
+		t.Fatal("нет ни одного раунда трассировки")
+	}
+
+	m.StopMTR()
+	select {
+	case <-m.MTRDone():
+	case <-time.After(3 * time.Second):
+		t.Fatal("трассировка не остановлена")
+	}
+	if m.MTRRunning() {
+		t.Error("трассировка считается запущенной после остановки")
+	}
+	// Результаты записаны до закрытия MTRDone
+	data, err := os.ReadFile(filepath.Join(opts.LogDir, "mtr_results.log"))
+	if err != nil || !strings.Contains(string(data), "127.0.0.1") {
+		t.Errorf("результаты трассировки не записаны: %v", err)
+	}
+	if err := <-finished; err != nil {
+		t.Errorf("ошибка трассировки: %v", err)
+	}
+	if last := m.LastMTR(); last == nil || last.Target != "127.0.0.1" {
+		t.Errorf("последняя трассировка: %+v", last)
+	}
+}
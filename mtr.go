@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// WinMTRHop содержит информацию об одном хопе и накопленную статистику по нему
+type WinMTRHop struct {
+	Hop      int
+	Address  string
+	RTT      time.Duration // RTT последнего полученного ответа
+	Success  bool
+	Sent     int
+	Received int
+	Loss     float64 // Потери, %
+	Last     time.Duration
+	Best     time.Duration
+	Avg      time.Duration
+	Worst    time.Duration
+	StdDev   time.Duration
+	Jitter   time.Duration // Среднее |RTT(i) - RTT(i-1)|
+
+	mean float64 // Для расчёта среднего и отклонения по Уэлфорду
+	m2   float64
+}
+
+// record учитывает полученный ответ
+func (h *WinMTRHop) record(rtt time.Duration) {
+	h.Received++
+	if h.Received > 1 {
+		diff := rtt - h.Last
+		if diff < 0 {
+			diff = -diff
+		}
+		h.Jitter += (diff - h.Jitter) / time.Duration(h.Received-1)
+	}
+	h.RTT = rtt
+	h.Last = rtt
+	if h.Received == 1 || rtt < h.Best {
+		h.Best = rtt
+	}
+	if rtt > h.Worst {
+		h.Worst = rtt
+	}
+
+	x := float64(rtt)
+	delta := x - h.mean
+	h.mean += delta / float64(h.Received)
+	h.m2 += delta * (x - h.mean)
+	h.Avg = time.Duration(h.mean)
+	h.StdDev = time.Duration(math.Sqrt(h.m2 / float64(h.Received)))
+}
+
+// updateLoss пересчитывает процент потерь
+func (h *WinMTRHop) updateLoss() {
+	if h.Sent > 0 {
+		h.Loss = float64(h.Sent-h.Received) * 100 / float64(h.Sent)
+	}
+}
+
+// mtrProbe — отправленный зонд, ожидающий ответа
+type mtrProbe struct {
+	ttl  int
+	sent time.Time
+}
+
+// mtrReceived — ответ, прочитанный из сокета, с временем получения
+type mtrReceived struct {
+	reply *icmpReply
+	at    time.Time
+}
+
+// mtrTracer выполняет ICMP-трассировку раундами: в каждом раунде
+// отправляется по одному зонду на каждый TTL, статистика копится между раундами
+type mtrTracer struct {
+	conn     *icmpConn
+	dst      *net.IPAddr
+	maxHops  int
+	timeout  time.Duration
+	hops     []WinMTRHop
+	lastHop  int // TTL, на котором ответила цель; 0 — ещё не известен
+	seq      int
+	replies  chan mtrReceived
+	readDone chan struct{}
+}
+
+// newMTRTracer разрешает адрес и открывает ICMP- или ICMPv6-сокет. Суффиксы
+// записи хоста выбирают семейство адресов (без него предпочитается IPv4) и
+// интерфейс, с которого идёт трассировка: "ya.ru [IPv6] @wg0".
+func newMTRTracer(host string, maxHops int, timeout time.Duration) (*mtrTracer, error) {
+	host, family, iface := splitHostKey(host)
+	ipAddr, err := net.ResolveIPAddr(familyNetwork("ip", family), host)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разрешить адрес: %v", err)
+	}
+
+	conn, err := listenICMPFrom(ipAddr.IP, iface)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &mtrTracer{
+		conn:     conn,
+		dst:      ipAddr,
+		maxHops:  maxHops,
+		timeout:  timeout,
+		hops:     make([]WinMTRHop, maxHops),
+		replies:  make(chan mtrReceived, 2*maxHops),
+		readDone: make(chan struct{}),
+	}
+	for i := range t.hops {
+		t.hops[i] = WinMTRHop{Hop: i + 1, Address: "*"}
+	}
+	go t.readLoop()
+	return t, nil
+}
+
+// readLoop читает ответы в отдельной горутине, чтобы RTT не зависел
+// от того, когда раунд доберётся до чтения сокета
+func (t *mtrTracer) readLoop() {
+	defer close(t.readDone)
+	for {
+		reply, err := t.conn.recv()
+		if err != nil {
+			return
+		}
+		if reply == nil {
+			continue
+		}
+		select {
+		case t.replies <- mtrReceived{reply: reply, at: time.Now()}:
+		default:
+			// Раунд не успевает читать, ответ отбрасываем
+		}
+	}
+}
+
+func (t *mtrTracer) Close() error {
+	err := t.conn.Close()
+	<-t.readDone
+	return err
+}
+
+// Mode возвращает режим ICMP-сокета, через который идёт трассировка
+func (t *mtrTracer) Mode() icmpMode {
+	return t.conn.mode
+}
+
+// limit возвращает число хопов, которые нужно опрашивать
+func (t *mtrTracer) limit() int {
+	if t.lastHop > 0 {
+		return t.lastHop
+	}
+	return t.maxHops
+}
+
+// round отправляет по зонду на каждый TTL и ждёт ответы не дольше timeout.
+// При отмене ctx ожидание прерывается, а зонды, ответ на которые ещё не
+// пришёл, не учитываются как отправленные: частичные результаты после
+// остановки не должны показывать ложные потери в последнем раунде.
+func (t *mtrTracer) round(ctx context.Context) {
+	limit := t.limit()
+	pending := make(map[int]mtrProbe, limit)
+	for ttl := 1; ttl <= limit; ttl++ {
+		t.seq = (t.seq + 1) & 0xffff
+		hop := &t.hops[ttl-1]
+		hop.Sent++
+		probe := mtrProbe{ttl: ttl, sent: time.Now()}
+		if err := t.conn.send(t.dst, ttl, t.seq, []byte("PINGSTATSMTR")); err != nil {
+			continue
+		}
+		pending[t.seq] = probe
+	}
+
+	deadline := time.NewTimer(t.timeout)
+	defer deadline.Stop()
+	for len(pending) > 0 {
+		select {
+		case r := <-t.replies:
+			probe, ok := pending[r.reply.Seq]
+			if !ok {
+				continue // опоздавший ответ из прошлого раунда
+			}
+			delete(pending, r.reply.Seq)
+			t.handleReply(probe, r)
+		case <-deadline.C:
+			pending = nil
+		case <-ctx.Done():
+			for _, probe := range pending {
+				t.hops[probe.ttl-1].Sent--
+			}
+			pending = nil
+		}
+	}
+
+	for i := 0; i < limit; i++ {
+		t.hops[i].updateLoss()
+	}
+}
+
+// handleReply учитывает ответ на зонд
+func (t *mtrTracer) handleReply(probe mtrProbe, r mtrReceived) {
+	hop := &t.hops[probe.ttl-1]
+	hop.Address = r.reply.Peer.String()
+	switch {
+	case r.reply.isTimeExceeded():
+		hop.Success = true
+	case r.reply.isEchoReply():
+		hop.Success = true
+		if t.lastHop == 0 || probe.ttl < t.lastHop {
+			t.lastHop = probe.ttl // достигли цели
+		}
+	case r.reply.isUnreachable():
+		hop.Success = false
+		if t.lastHop == 0 || probe.ttl < t.lastHop {
+			t.lastHop = probe.ttl // дальше маршрута нет
+		}
+	default:
+		hop.Success = false
+	}
+	hop.record(r.at.Sub(probe.sent))
+}
+
+// Hops возвращает копию статистики по опрашиваемым хопам
+func (t *mtrTracer) Hops() []WinMTRHop {
+	hops := make([]WinMTRHop, t.limit())
+	copy(hops, t.hops)
+	return hops
+}
+
+// continuousMTR опрашивает все хопы раунд за раундом с паузой interval,
+// пока не будет отменён ctx. После каждого раунда вызывается update.
+// Возвращает статистику на момент остановки.
+func continuousMTR(ctx context.Context, host string, maxHops int, timeout, interval time.Duration, update func([]WinMTRHop, icmpMode)) ([]WinMTRHop, icmpMode, error) {
+	t, err := newMTRTracer(host, maxHops, timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	defer t.Close()
+
+	for {
+		t.round(ctx)
+		update(t.Hops(), t.Mode())
+
+		select {
+		case <-ctx.Done():
+			return t.Hops(), t.Mode(), nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Форматированный вывод для CLI/GUI
+func FormatWinMTRResult(hops []WinMTRHop) string {
+	result := fmt.Sprintf("%-4s %-16s %7s %5s %5s %8s %8s %8s %8s %8s %8s\n",
+		"Hop", "Address", "Loss%", "Snt", "Rcv", "Last", "Avg", "Best", "Wrst", "StDev", "Jttr")
+	for _, h := range hops {
+		result += fmt.Sprintf("%-4d %-16s %6.1f%% %5d %5d %8.2f %8.2f %8.2f %8.2f %8.2f %8.2f\n",
+			h.Hop, h.Address, h.Loss, h.Sent, h.Received,
+			durationMs(h.Last), durationMs(h.Avg), durationMs(h.Best),
+			durationMs(h.Worst), durationMs(h.StdDev), durationMs(h.Jitter))
+	}
+	return result
+}
+
+// durationMs переводит длительность в миллисекунды
+func durationMs(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Остановка трассировки посреди раунда не добавляет потерь: зонды без
+// ответа на момент отмены не считаются отправленными
+func TestMTRRoundCancelled(t *testing.T) {
+	tracer, err := newMTRTracer("127.0.0.1", 4, time.Second)
+	if err != nil {
+		t.Skipf("ICMP-сокет недоступен: %v", err)
+	}
+	defer tracer.Close()
+
+	// Первый раунд до конца: цель отвечает с первого TTL
+	tracer.round(context.Background())
+	hops := tracer.Hops()
+	if len(hops) != 1 || hops[0].Sent != 1 || hops[0].Received != 1 {
+		t.Fatalf("первый раунд: %+v", hops)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 5; i++ {
+		tracer.round(ctx)
+	}
+	for _, hop := range tracer.Hops() {
+		if hop.Sent != hop.Received || hop.Loss != 0 {
+			t.Errorf("хоп %d после отмены: отправлено %d, получено %d, потери %.1f%%", hop.Hop, hop.Sent, hop.Received, hop.Loss)
+		}
+	}
+}
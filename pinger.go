@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Способы измерения задержки
+const (
+	pingBackendICMP = "icmp" // Встроенный пингер на ICMP-сокете
+	pingBackendExec = "exec" // Системная утилита ping
+	pingBackendTCP  = "tcp"  // Время установки TCP-соединения
+)
+
+// ProbeOptions — параметры серии эхо-запросов
+type ProbeOptions struct {
+	Backend  string // Способ проверки хостов, записанных без схемы: pingBackendICMP, pingBackendExec или pingBackendTCP
+	Count    int
+	Size     int           // Размер полезной нагрузки, байт
+	Interval time.Duration // Пауза между пакетами
+	Timeout  time.Duration
+	TCPPort  int    // Порт для tcp:// без явного порта
+	DNSName  string // Имя и тип записи для проверок DNS без явного запроса
+	DNSType  string
+
+	CertWarnDays int      // Предупреждать, если до истечения сертификата осталось меньше дней
+	DualStack    bool     // Проверять имена с адресами IPv4 и IPv6 по каждому семейству отдельно
+	Interfaces   []string // Интерфейсы, с каждого из которых проверяются хосты; пусто — по таблице маршрутизации
+}
+
+// pingResult — результат одного эхо-запроса
+type pingResult struct {
+	Seq      int
+	RTT      time.Duration
+	Received bool
+	Fail     string // Причина неудачи (failTimeout, failRefused, ...), если ответа нет
+}
+
+// errICMPSocket — ICMP-сокет не открылся: нет прав на raw-сокет или протокол
+// не поддерживается. Только в этом случае icmpProber переходит на утилиту ping.
+var errICMPSocket = errors.New("не удалось открыть ICMP-сокет")
+
+// icmpPing отправляет count эхо-запросов на t.Host с паузой interval
+// и ждёт ответ на каждый не дольше timeout. Имя разрешается в адрес
+// семейства t.Family, без семейства предпочитается IPv4; для IPv6 используется
+// ICMPv6. Если задан t.Interface, запросы отправляются с его адреса.
+// При отмене ctx серия прерывается.
+func icmpPing(ctx context.Context, t Target, count, size int, interval, timeout time.Duration) ([]pingResult, icmpMode, error) {
+	ipAddr, err := net.ResolveIPAddr(familyNetwork("ip", t.Family), t.Host)
+	if err != nil {
+		return nil, "", fmt.Errorf("не удалось разрешить адрес: %w", err)
+	}
+
+	conn, err := listenICMPFrom(ipAddr.IP, t.Interface)
+	if err != nil {
+		return nil, "", err
+	}
+	defer conn.Close()
+
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	results := make([]pingResult, 0, count)
+	for seq := 1; seq <= count && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		result := pingResult{Seq: seq}
+		reply, err := icmpEcho(conn, ipAddr, seq, data, start.Add(timeout))
+		switch {
+		case err != nil:
+			result.Fail = classifyError(err)
+		case reply.isEchoReply() && reply.Peer.Equal(ipAddr.IP):
+			result.RTT = time.Since(start)
+			result.Received = true
+		case reply.isUnreachable():
+			result.Fail = failUnreachable
+		default:
+			result.Fail = failOther
+		}
+		results = append(results, result)
+
+		if seq < count {
+			sleepUntil(ctx, start.Add(interval))
+		}
+	}
+	return results, conn.mode, nil
+}
+
+// icmpEcho отправляет эхо-запрос с номером seq и ждёт ответ до deadline
+func icmpEcho(conn *icmpConn, dst *net.IPAddr, seq int, data []byte, deadline time.Time) (*icmpReply, error) {
+	if err := conn.send(dst, 64, seq, data); err != nil {
+		return nil, err
+	}
+	return conn.recvSeq(seq, deadline)
+}
+
+// statsFromResults считает статистику по результатам отдельных эхо-запросов
+func statsFromResults(host string, results []pingResult) *PingStats {
+	stats := &PingStats{
+		Host:       host,
+		LastUpdate: time.Now(),
+		PacketLoss: 100,
+	}
+	if len(results) == 0 {
+		return stats
+	}
+
+	received := 0
+	var sum float64
+	for _, r := range results {
+		if !r.Received {
+			reason := r.Fail
+			if reason == "" {
+				reason = failTimeout
+			}
+			if stats.Failures == nil {
+				stats.Failures = make(map[string]int)
+			}
+			stats.Failures[reason]++
+			continue
+		}
+		rtt := durationMs(r.RTT)
+		if received == 0 || rtt < stats.MinRTT {
+			stats.MinRTT = rtt
+		}
+		if rtt > stats.MaxRTT {
+			stats.MaxRTT = rtt
+		}
+		sum += rtt
+		received++
+		stats.Samples = append(stats.Samples, rtt)
+	}
+	if received > 0 {
+		stats.AvgRTT = sum / float64(received)
+	}
+	stats.Sent, stats.Received = len(results), received
+	stats.PacketLoss = float64(len(results)-received) * 100 / float64(len(results))
+	return stats
+}
+
+// formatPingResults формирует вывод в духе утилиты ping
+func formatPingResults(results []pingResult, stats *PingStats) string {
+	var b strings.Builder
+	for _, r := range results {
+		if r.Received {
+			fmt.Fprintf(&b, "seq=%d время=%.2f мс\n", r.Seq, durationMs(r.RTT))
+		} else if r.Fail == "" || r.Fail == failTimeout {
+			fmt.Fprintf(&b, "seq=%d превышен интервал ожидания\n", r.Seq)
+		} else {
+			fmt.Fprintf(&b, "seq=%d %s\n", r.Seq, failReasonName(r.Fail))
+		}
+	}
+	fmt.Fprintf(&b, "Потери: %.1f%%, мин/сред/макс = %.2f/%.2f/%.2f мс\n",
+		stats.PacketLoss, stats.MinRTT, stats.AvgRTT, stats.MaxRTT)
+	return b.String()
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Target — цель проверки, разобранная из записи хоста
+type Target struct {
+	Raw       string // Запись хоста как в конфигурации, по ней хранится статистика
+	Scheme    string // Способ проверки: icmp, exec, tcp, http, https, tls, dns, dns+tcp, dot
+	Host      string // Имя или адрес без порта
+	Port      string
+	Family    string    // familyIPv4 или familyIPv6 для записи с суффиксом семейства; пустое — любое
+	Interface string    // Интерфейс, с которого выполняется проверка ("8.8.8.8 @wg0"); пустое — по таблице маршрутизации
+	URL       string    // Полный адрес для http(s)
+	HTTP      HTTPCheck // Проверки ответа http(s) из конфигурации хоста
+	DNS       dnsQuery  // Запрос для dns, dns+tcp и dot
+}
+
+// ProbeReport — результаты серии проверок цели
+type ProbeReport struct {
+	Results []pingResult
+	Mode    string     // Как выполнялась проверка, для лога
+	Prober  string     // Схема способа проверки, заполняется probeTarget
+	Output  string     // Текст для лога; пустой — формируется по Results
+	HTTP    *HTTPStats // Этапы запроса и код ответа для http(s)
+	DNS     *DNSStats  // Код ответа и число записей для проверок DNS
+	TLS     *TLSStats  // Сертификат и параметры соединения для tls и https
+}
+
+// Prober измеряет доступность цели. Реализация выполняет серию из
+// opts.Count попыток и возвращает результат каждой; неудачная попытка
+// считается потерянным пакетом. Ошибка возвращается, только если
+// проверку не удалось выполнить совсем.
+type Prober interface {
+	Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error)
+}
+
+// probers — способы проверки по схеме адреса хоста
+var probers = map[string]Prober{
+	pingBackendICMP: &icmpProber{},
+	pingBackendExec: execProber{},
+	pingBackendTCP:  tcpProber{},
+	"http":          httpProber{},
+	"https":         httpProber{},
+	schemeDNS:       dnsProber{},
+	schemeDNSTCP:    dnsProber{},
+	schemeDoT:       dnsProber{},
+	"tls":           tlsProber{},
+}
+
+// proberSchemes возвращает список известных схем для сообщений об ошибках
+func proberSchemes() string {
+	schemes := make([]string, 0, len(probers))
+	for scheme := range probers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return strings.Join(schemes, ", ")
+}
+
+// parseTarget разбирает запись хоста: "8.8.8.8", "icmp://8.8.8.8",
+// "tcp://github.com:443", "https://ya.ru/", "tls://github.com",
+// "dns://8.8.8.8/ya.ru?type=AAAA", "ya.ru [IPv6]", "8.8.8.8 @wg0".
+// Для записи без схемы
+// используется defaultScheme, для tcp:// без порта — ProbeOptions.TCPPort.
+func parseTarget(entry, defaultScheme string) (Target, error) {
+	raw := strings.TrimSpace(entry)
+	entry, family, iface := splitHostKey(raw)
+	if !strings.Contains(entry, "://") {
+		if entry == "" {
+			return Target{}, fmt.Errorf("пустой адрес хоста")
+		}
+		t := Target{Raw: raw, Scheme: defaultScheme, Host: entry, Family: family, Interface: iface}
+		return t, t.checkFamily()
+	}
+
+	u, err := url.Parse(entry)
+	if err != nil {
+		return Target{}, fmt.Errorf("неверный адрес %q: %v", entry, err)
+	}
+	t := Target{Raw: raw, Scheme: strings.ToLower(u.Scheme), Host: u.Hostname(), Port: u.Port(), Family: family, Interface: iface}
+	if _, ok := probers[t.Scheme]; !ok {
+		return Target{}, fmt.Errorf("неизвестный способ проверки %q в %q (допустимо: %s)", t.Scheme, entry, proberSchemes())
+	}
+	if t.Host == "" {
+		return Target{}, fmt.Errorf("в адресе %q не указан хост", entry)
+	}
+	if err := t.checkFamily(); err != nil {
+		return Target{}, err
+	}
+	switch t.Scheme {
+	case pingBackendICMP, pingBackendExec:
+		if t.Port != "" {
+			return Target{}, fmt.Errorf("для %s:// порт не указывается: %q", t.Scheme, entry)
+		}
+	case "http", "https":
+		t.URL = entry
+	case schemeDNS, schemeDNSTCP, schemeDoT:
+		t.DNS = dnsQuery{Name: strings.Trim(u.Path, "/"), Type: u.Query().Get("type")}
+		if t.DNS.Type != "" {
+			if _, err := parseDNSType(t.DNS.Type); err != nil {
+				return Target{}, fmt.Errorf("%v в %q", err, entry)
+			}
+		}
+	}
+	return t, nil
+}
+
+// checkFamily проверяет, что IP-адрес цели относится к семейству из суффикса записи
+func (t Target) checkFamily() error {
+	if ip := net.ParseIP(t.Host); ip != nil && t.Family != "" && ipFamily(ip) != t.Family {
+		return fmt.Errorf("адрес %s не относится к %s", t.Host, t.Family)
+	}
+	return nil
+}
+
+// addrFamily возвращает семейство адресов цели, если оно известно без
+// разрешения имени: из суффикса записи или по IP-адресу
+func (t Target) addrFamily() string {
+	if t.Family != "" {
+		return t.Family
+	}
+	if ip := net.ParseIP(t.Host); ip != nil {
+		return ipFamily(ip)
+	}
+	return ""
+}
+
+// icmpProber пингует встроенным ICMP-пингером. Если ICMP-сокет не
+// открывается (нет прав или протокол не поддерживается), проверки один раз
+// и до конца работы переводятся на системную утилиту ping. Другие ошибки,
+// например неразрешённое имя, возвращаются как есть.
+type icmpProber struct {
+	mu       sync.Mutex
+	fallback bool // ICMP-сокет недоступен, пингуем утилитой ping
+}
+
+func (p *icmpProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	p.mu.Lock()
+	fallback := p.fallback
+	p.mu.Unlock()
+	if fallback {
+		return execProber{}.Probe(ctx, t, opts)
+	}
+
+	results, mode, err := icmpPing(ctx, t, opts.Count, opts.Size, opts.Interval, opts.Timeout)
+	if errors.Is(err, errICMPSocket) {
+		p.mu.Lock()
+		if !p.fallback {
+			p.fallback = true
+			log.Printf("Встроенный пинг недоступен: %v, дальше используем утилиту ping", err)
+		}
+		p.mu.Unlock()
+		return execProber{}.Probe(ctx, t, opts)
+	}
+	if err != nil {
+		return ProbeReport{}, err
+	}
+	return ProbeReport{Results: results, Mode: string(mode)}, nil
+}
+
+// execProber пингует системной утилитой ping
+type execProber struct{}
+
+func (execProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	stats, output, err := pingHostExec(ctx, t, opts)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+
+	// Утилита сообщает RTT полученных ответов и число отправленных пакетов,
+	// неполученные ответы считаем потерянными
+	results := make([]pingResult, 0, stats.Sent)
+	for i, rtt := range stats.Samples {
+		results = append(results, pingResult{Seq: i + 1, RTT: time.Duration(rtt * float64(time.Millisecond)), Received: true})
+	}
+	for seq := len(results) + 1; seq <= stats.Sent; seq++ {
+		results = append(results, pingResult{Seq: seq})
+	}
+	return ProbeReport{Results: results, Mode: "утилита ping", Output: output}, nil
+}
+
+// Причины неудачных попыток
+const (
+	failTimeout     = "timeout"
+	failRefused     = "refused"
+	failReset       = "reset"
+	failUnreachable = "unreachable"
+	failDNS         = "dns"
+	failStatus      = "status" // Неожиданный код ответа HTTP
+	failBody        = "body"   // Ответ HTTP не прошёл проверку содержимого
+	failRcode       = "rcode"  // DNS-сервер ответил кодом ошибки
+	failCert        = "cert"   // Сертификат TLS не прошёл проверку
+	failOther       = "error"
+)
+
+// failReasonNames — подписи причин неудачных попыток для логов и GUI
+var failReasonNames = map[string]string{
+	failTimeout:     "таймаут",
+	failRefused:     "отказ в соединении",
+	failReset:       "сброс соединения",
+	failUnreachable: "хост недоступен",
+	failDNS:         "ошибка DNS",
+	failStatus:      "неверный код ответа",
+	failBody:        "неверное содержимое ответа",
+	failRcode:       "ошибка сервера DNS",
+	failCert:        "недействительный сертификат",
+	failOther:       "ошибка",
+}
+
+// failReasonName возвращает подпись причины неудачной попытки
+func failReasonName(reason string) string {
+	if name, ok := failReasonNames[reason]; ok {
+		return name
+	}
+	return reason
+}
+
+// classifyError определяет причину неудачной попытки по ошибке подключения
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	var errno syscall.Errno
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return failDNS
+	case errors.As(err, new(*tls.CertificateVerificationError)):
+		return failCert
+	case errors.As(err, &errno):
+		switch {
+		case slices.Contains(errnoConnRefused, errno):
+			return failRefused
+		case slices.Contains(errnoConnReset, errno):
+			return failReset
+		case slices.Contains(errnoUnreachable, errno):
+			return failUnreachable
+		case errno.Timeout():
+			return failTimeout
+		}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return failTimeout
+	case errors.Is(err, io.EOF):
+		return failReset
+	}
+	return failOther
+}
+
+// lostResults возвращает count неудачных попыток
+func lostResults(count int) []pingResult {
+	results := make([]pingResult, count)
+	for i := range results {
+		results[i].Seq = i + 1
+	}
+	return results
+}
+
+// sleepUntil ждёт наступления t или отмены ctx
+func sleepUntil(ctx context.Context, t time.Time) {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
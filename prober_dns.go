@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Схемы адресов для проверки DNS-серверов
+const (
+	schemeDNS    = "dns"     // Запрос по UDP
+	schemeDNSTCP = "dns+tcp" // Запрос по TCP
+	schemeDoT    = "dot"     // DNS поверх TLS (RFC 7858)
+)
+
+// dnsTransports — протокол и порт по умолчанию для схем DNS
+var dnsTransports = map[string]struct {
+	network string
+	port    string
+}{
+	schemeDNS:    {"udp", "53"},
+	schemeDNSTCP: {"tcp", "53"},
+	schemeDoT:    {"tls", "853"},
+}
+
+// dnsTypes — типы записей, которые можно запрашивать
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// defaultDNSResolvers — публичные DNS-серверы, которые проверяются при
+// обнаружении хостов
+var defaultDNSResolvers = []string{"8.8.8.8", "1.1.1.1", "77.88.8.8"}
+
+// defaultDNSResolversV6 — те же серверы по IPv6, проверяются, если у
+// устройства есть адрес IPv6
+var defaultDNSResolversV6 = []string{"2001:4860:4860::8888", "2606:4700:4700::1111", "2a02:6b8::feed:0ff"}
+
+// dnsQuery — запрос из адреса хоста вида dns://8.8.8.8/ya.ru?type=AAAA.
+// Пустые поля берутся из ProbeOptions.
+type dnsQuery struct {
+	Name string
+	Type string
+}
+
+// parseDNSType проверяет тип записи DNS
+func parseDNSType(name string) (dnsmessage.Type, error) {
+	t, ok := dnsTypes[strings.ToUpper(name)]
+	if !ok {
+		types := make([]string, 0, len(dnsTypes))
+		for t := range dnsTypes {
+			types = append(types, t)
+		}
+		sort.Strings(types)
+		return 0, fmt.Errorf("неизвестный тип записи DNS %q (допустимо: %s)", name, strings.Join(types, ", "))
+	}
+	return t, nil
+}
+
+// dnsProber измеряет время ответа DNS-сервера на запрос записи. Для TCP и
+// DoT в время входит установка соединения, как у обычного клиента.
+// Успешным считается только ответ NOERROR: NXDOMAIN на запрос имени, которое
+// должно существовать, как и SERVFAIL или REFUSED, учитывается как потеря.
+type dnsProber struct{}
+
+func (dnsProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	transport := dnsTransports[t.Scheme]
+	port := t.Port
+	if port == "" {
+		port = transport.port
+	}
+	server := net.JoinHostPort(t.Host, port)
+
+	name, typeName := t.DNS.Name, t.DNS.Type
+	if name == "" {
+		name = opts.DNSName
+	}
+	if typeName == "" {
+		typeName = opts.DNSType
+	}
+	qtype, err := parseDNSType(typeName)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+	qname, err := dnsmessage.NewName(strings.TrimSuffix(name, ".") + ".")
+	if err != nil {
+		return ProbeReport{}, fmt.Errorf("неверное имя для запроса DNS %q: %v", name, err)
+	}
+
+	// Для DoT соединение устанавливает tls.Dialer поверх того же net.Dialer
+	dialNetwork := transport.network
+	if dialNetwork == "tls" {
+		dialNetwork = "tcp"
+	}
+	dialer, err := sourceDialer(t.Interface, t.addrFamily(), dialNetwork, 0)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+
+	stats := &DNSStats{Server: server, Transport: transport.network, Name: name, Type: strings.ToUpper(typeName)}
+	results := make([]pingResult, 0, opts.Count)
+	for seq := 1; seq <= opts.Count && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		result := pingResult{Seq: seq}
+		header, answers, err := dnsExchange(ctx, dialer, transport.network, t.Family, server, t.Host, qname, qtype, opts.Timeout)
+		switch {
+		case err != nil:
+			result.Fail = classifyError(err)
+			stats.Error = err.Error()
+		case header.RCode != dnsmessage.RCodeSuccess:
+			result.Fail = failRcode
+			stats.Rcode, stats.Answers = rcodeName(header.RCode), answers
+		default:
+			result.RTT = time.Since(start)
+			result.Received = true
+			stats.Rcode, stats.Answers = rcodeName(header.RCode), answers
+		}
+		results = append(results, result)
+
+		if seq < opts.Count {
+			sleepUntil(ctx, start.Add(opts.Interval))
+		}
+	}
+	mode := fmt.Sprintf("DNS %s %s %s", transport.network, stats.Type, name)
+	return ProbeReport{Results: results, Mode: mode, DNS: stats}, nil
+}
+
+// dnsExchange отправляет запрос серверу через dialer и возвращает заголовок
+// ответа и число записей в разделе ответов. family ограничивает семейство
+// адресов сервера, заданного именем.
+func dnsExchange(ctx context.Context, dialer *net.Dialer, network, family, server, tlsName string, name dnsmessage.Name, qtype dnsmessage.Type, timeout time.Duration) (dnsmessage.Header, int, error) {
+	id := uint16(rand.UintN(1 << 16))
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packet, err := query.Pack()
+	if err != nil {
+		return dnsmessage.Header{}, 0, fmt.Errorf("ошибка формирования запроса DNS: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var conn net.Conn
+	switch network {
+	case "tls":
+		tlsDialer := tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: tlsName}}
+		conn, err = tlsDialer.DialContext(ctx, familyNetwork("tcp", family), server)
+	default:
+		conn, err = dialer.DialContext(ctx, familyNetwork(network, family), server)
+	}
+	if err != nil {
+		return dnsmessage.Header{}, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var reply []byte
+	if network == "udp" {
+		reply, err = dnsExchangeUDP(conn, packet, id)
+	} else {
+		reply, err = dnsExchangeStream(conn, packet)
+	}
+	if err != nil {
+		return dnsmessage.Header{}, 0, err
+	}
+
+	var p dnsmessage.Parser
+	header, err := p.Start(reply)
+	if err != nil {
+		return dnsmessage.Header{}, 0, fmt.Errorf("ошибка разбора ответа DNS: %v", err)
+	}
+	if header.ID != id || !header.Response {
+		return dnsmessage.Header{}, 0, fmt.Errorf("ответ DNS не соответствует запросу")
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return dnsmessage.Header{}, 0, fmt.Errorf("ошибка разбора ответа DNS: %v", err)
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return dnsmessage.Header{}, 0, fmt.Errorf("ошибка разбора ответа DNS: %v", err)
+	}
+	return header, len(answers), nil
+}
+
+// dnsExchangeUDP отправляет запрос датаграммой и ждёт ответ с тем же ID.
+// Чужие и повреждённые датаграммы пропускаются до истечения таймаута.
+func dnsExchangeUDP(conn net.Conn, packet []byte, id uint16) ([]byte, error) {
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// dnsExchangeStream отправляет запрос и читает ответ по TCP или TLS:
+// каждое сообщение предваряется двухбайтовой длиной (RFC 1035, 4.2.2)
+func dnsExchangeStream(conn net.Conn, packet []byte) ([]byte, error) {
+	msg := make([]byte, 2+len(packet))
+	binary.BigEndian.PutUint16(msg, uint16(len(packet)))
+	copy(msg[2:], packet)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// rcodeName возвращает название кода ответа DNS: NOERROR, NXDOMAIN, ...
+func rcodeName(rcode dnsmessage.RCode) string {
+	switch rcode {
+	case dnsmessage.RCodeSuccess:
+		return "NOERROR"
+	case dnsmessage.RCodeFormatError:
+		return "FORMERR"
+	case dnsmessage.RCodeServerFailure:
+		return "SERVFAIL"
+	case dnsmessage.RCodeNameError:
+		return "NXDOMAIN"
+	case dnsmessage.RCodeNotImplemented:
+		return "NOTIMP"
+	case dnsmessage.RCodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// dnsComparison отбирает из снимка статистику DNS-серверов и упорядочивает
+// её от лучшего к худшему: по потерям за сессию, затем по среднему времени ответа
+func dnsComparison(snapshot []PingStats) []PingStats {
+	var resolvers []PingStats
+	for _, s := range snapshot {
+		if s.DNS != nil {
+			resolvers = append(resolvers, s)
+		}
+	}
+	sort.SliceStable(resolvers, func(i, j int) bool {
+		a, b := &resolvers[i], &resolvers[j]
+		if a.TotalLoss != b.TotalLoss {
+			return a.TotalLoss < b.TotalLoss
+		}
+		return a.RunningMean < b.RunningMean
+	})
+	return resolvers
+}
+
+// formatDNSComparison формирует таблицу сравнения DNS-серверов
+func formatDNSComparison(snapshot []PingStats) string {
+	resolvers := dnsComparison(snapshot)
+	if len(resolvers) == 0 {
+		return "Нет данных о DNS-серверах. Добавьте хосты вида dns://8.8.8.8/ya.ru\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-3s %-28s %-5s %-24s %10s %10s %8s %9s %-9s %s\n",
+		"№", "Сервер", "Прот.", "Запрос", "Среднее", "p95", "Потери", "Таймауты", "Код", "Ответов")
+	for i := range resolvers {
+		s := &resolvers[i]
+		fmt.Fprintf(&b, "%-3d %-28s %-5s %-24s %7.2f мс %7.2f мс %7.1f%% %9d %-9s %d\n",
+			i+1, s.DisplayName(), s.DNS.Transport, s.DNS.Type+" "+s.DNS.Name,
+			s.RunningMean, s.P95, s.TotalLoss, s.TotalFailures[failTimeout], s.DNS.Rcode, s.DNS.Answers)
+	}
+	return b.String()
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Имена, на которые тестовый DNS-сервер отвечает по-разному
+const (
+	dnsTestOK       = "ok.test."       // NOERROR, две записи A
+	dnsTestEmpty    = "empty.test."    // NOERROR без записей
+	dnsTestNX       = "nx.test."       // NXDOMAIN
+	dnsTestServFail = "servfail.test." // SERVFAIL
+	dnsTestRefused  = "refused.test."  // REFUSED
+	dnsTestSilent   = "silent.test."   // Ответа нет
+	dnsTestWrongID  = "wrongid.test."  // Сначала ответ с чужим ID, затем (по UDP) правильный
+	dnsTestQuery    = "query.test."    // Ответ без флага QR — эхо запроса
+)
+
+// dnsTestServer — локальный DNS-сервер для тестов на 127.0.0.1 по UDP и TCP
+type dnsTestServer struct {
+	udp net.PacketConn
+	tcp net.Listener
+}
+
+func newDNSTestServer(t *testing.T) *dnsTestServer {
+	t.Helper()
+	udp, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		udp.Close()
+		t.Fatal(err)
+	}
+	s := &dnsTestServer{udp: udp, tcp: tcp}
+	t.Cleanup(func() {
+		udp.Close()
+		tcp.Close()
+	})
+	go s.serveUDP()
+	go s.serveTCP()
+	return s
+}
+
+// url возвращает адрес хоста для проверки сервера по схеме scheme
+func (s *dnsTestServer) url(scheme, name string) string {
+	addr := s.udp.LocalAddr().String()
+	if scheme == schemeDNSTCP {
+		addr = s.tcp.Addr().String()
+	}
+	return scheme + "://" + addr + "/" + strings.TrimSuffix(name, ".") + "?type=A"
+}
+
+func (s *dnsTestServer) serveUDP() {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for _, reply := range dnsTestReplies(buf[:n], true) {
+			s.udp.WriteTo(reply, addr)
+		}
+	}
+}
+
+func (s *dnsTestServer) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			var length [2]byte
+			if _, err := io.ReadFull(conn, length[:]); err != nil {
+				return
+			}
+			query := make([]byte, binary.BigEndian.Uint16(length[:]))
+			if _, err := io.ReadFull(conn, query); err != nil {
+				return
+			}
+			replies := dnsTestReplies(query, false)
+			if len(replies) == 0 {
+				// Держим соединение до закрытия клиентом
+				io.Copy(io.Discard, conn)
+				return
+			}
+			reply := replies[0]
+			msg := binary.BigEndian.AppendUint16(nil, uint16(len(reply)))
+			conn.Write(append(msg, reply...))
+		}()
+	}
+}
+
+// dnsTestReplies возвращает ответы на запрос в зависимости от имени.
+// По UDP на dnsTestWrongID после чужого ответа отправляется правильный.
+func dnsTestReplies(query []byte, udp bool) [][]byte {
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	reply := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: header.ID, Response: true, RecursionDesired: header.RecursionDesired, RecursionAvailable: true},
+		Questions: []dnsmessage.Question{q},
+	}
+	answer := func(ip [4]byte) dnsmessage.Resource {
+		return dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: ip},
+		}
+	}
+	pack := func(m dnsmessage.Message) []byte {
+		b, err := m.Pack()
+		if err != nil {
+			panic(err)
+		}
+		return b
+	}
+
+	switch q.Name.String() {
+	case dnsTestOK:
+		reply.Answers = []dnsmessage.Resource{answer([4]byte{192, 0, 2, 1}), answer([4]byte{192, 0, 2, 2})}
+	case dnsTestEmpty:
+	case dnsTestNX:
+		reply.RCode = dnsmessage.RCodeNameError
+	case dnsTestServFail:
+		reply.RCode = dnsmessage.RCodeServerFailure
+	case dnsTestRefused:
+		reply.RCode = dnsmessage.RCodeRefused
+	case dnsTestSilent:
+		return nil
+	case dnsTestWrongID:
+		reply.Answers = []dnsmessage.Resource{answer([4]byte{192, 0, 2, 3})}
+		right := pack(reply)
+		reply.Header.ID++
+		if udp {
+			return [][]byte{pack(reply), right}
+		}
+		return [][]byte{pack(reply)}
+	case dnsTestQuery:
+		reply.Header.Response = false
+	}
+	return [][]byte{pack(reply)}
+}
+
+// dnsTestOptions — две быстрые попытки с коротким таймаутом
+var dnsTestOptions = ProbeOptions{Count: 2, Interval: 5 * time.Millisecond, Timeout: 300 * time.Millisecond}
+
+func probeDNSTest(t *testing.T, url string) ProbeReport {
+	t.Helper()
+	target, err := parseTarget(url, pingBackendICMP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := dnsProber{}.Probe(context.Background(), target, dnsTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != dnsTestOptions.Count || report.DNS == nil {
+		t.Fatalf("попыток %d, сведения DNS %+v", len(report.Results), report.DNS)
+	}
+	return report
+}
+
+func TestDNSProber(t *testing.T) {
+	srv := newDNSTestServer(t)
+	tests := []struct {
+		name      string
+		qname     string
+		wantFail  string // Причина потери каждой попытки; пусто — попытки успешны
+		wantRcode string
+		answers   int
+		wantErr   string // Подстрока ошибки в DNSStats.Error
+	}{
+		{"записи найдены", dnsTestOK, "", "NOERROR", 2, ""},
+		{"записей нет", dnsTestEmpty, "", "NOERROR", 0, ""},
+		{"NXDOMAIN", dnsTestNX, failRcode, "NXDOMAIN", 0, ""},
+		{"SERVFAIL", dnsTestServFail, failRcode, "SERVFAIL", 0, ""},
+		{"REFUSED", dnsTestRefused, failRcode, "REFUSED", 0, ""},
+		{"ответ без флага QR", dnsTestQuery, failOther, "", 0, "не соответствует запросу"},
+		{"нет ответа", dnsTestSilent, failTimeout, "", 0, "timeout"},
+	}
+	for _, scheme := range []string{schemeDNS, schemeDNSTCP} {
+		for _, tt := range tests {
+			t.Run(scheme+"/"+tt.name, func(t *testing.T) {
+				report := probeDNSTest(t, srv.url(scheme, tt.qname))
+				for _, r := range report.Results {
+					if r.Fail != tt.wantFail || r.Received != (tt.wantFail == "") {
+						t.Errorf("попытка %d: получен %v, причина %q; ожидалась %q", r.Seq, r.Received, r.Fail, tt.wantFail)
+					}
+					if r.Received && r.RTT <= 0 {
+						t.Errorf("попытка %d: время ответа %v", r.Seq, r.RTT)
+					}
+				}
+				d := report.DNS
+				if d.Rcode != tt.wantRcode || d.Answers != tt.answers {
+					t.Errorf("код %q, записей %d; ожидалось %q, %d", d.Rcode, d.Answers, tt.wantRcode, tt.answers)
+				}
+				if tt.wantErr == "" && d.Error != "" || !strings.Contains(d.Error, tt.wantErr) {
+					t.Errorf("ошибка %q, ожидалась %q", d.Error, tt.wantErr)
+				}
+				wantTransport := map[string]string{schemeDNS: "udp", schemeDNSTCP: "tcp"}[scheme]
+				if d.Transport != wantTransport || d.Type != "A" || d.Name != strings.TrimSuffix(tt.qname, ".") {
+					t.Errorf("запрос %s %s %s", d.Transport, d.Type, d.Name)
+				}
+			})
+		}
+	}
+}
+
+// Ответ с чужим ID: по UDP он пропускается и принимается следующий,
+// по TCP это ошибка
+func TestDNSProberIDMismatch(t *testing.T) {
+	srv := newDNSTestServer(t)
+
+	report := probeDNSTest(t, srv.url(schemeDNS, dnsTestWrongID))
+	for _, r := range report.Results {
+		if !r.Received {
+			t.Errorf("udp: попытка %d не прошла: %q, %s", r.Seq, r.Fail, report.DNS.Error)
+		}
+	}
+	if report.DNS.Answers != 1 {
+		t.Errorf("udp: записей %d, ожидалась 1", report.DNS.Answers)
+	}
+
+	report = probeDNSTest(t, srv.url(schemeDNSTCP, dnsTestWrongID))
+	for _, r := range report.Results {
+		if r.Received || r.Fail != failOther {
+			t.Errorf("tcp: попытка %d: получен %v, причина %q; ожидалось %q", r.Seq, r.Received, r.Fail, failOther)
+		}
+	}
+	if !strings.Contains(report.DNS.Error, "не соответствует запросу") {
+		t.Errorf("tcp: ошибка %q", report.DNS.Error)
+	}
+}
+
+// Таймаут ограничивает ожидание каждой попытки, а не всей серии
+func TestDNSProberTimeout(t *testing.T) {
+	srv := newDNSTestServer(t)
+	start := time.Now()
+	probeDNSTest(t, srv.url(schemeDNS, dnsTestSilent))
+	elapsed := time.Since(start)
+	if want := time.Duration(dnsTestOptions.Count) * dnsTestOptions.Timeout; elapsed < want || elapsed > want+time.Second {
+		t.Errorf("серия из %d попыток длилась %v при таймауте %v", dnsTestOptions.Count, elapsed, dnsTestOptions.Timeout)
+	}
+}
+
+func TestDNSComparison(t *testing.T) {
+	snapshot := []PingStats{
+		{Host: "dns://1.1.1.1", TotalLoss: 0, RunningMean: 20, DNS: &DNSStats{}},
+		{Host: "8.8.8.8", TotalLoss: 0, RunningMean: 5},
+		{Host: "dns://8.8.8.8", TotalLoss: 0, RunningMean: 10, DNS: &DNSStats{}},
+		{Host: "dns://77.88.8.8", TotalLoss: 25, RunningMean: 1, DNS: &DNSStats{}},
+	}
+	got := dnsComparison(snapshot)
+	want := []string{"dns://8.8.8.8", "dns://1.1.1.1", "dns://77.88.8.8"}
+	if len(got) != len(want) {
+		t.Fatalf("серверов %d, ожидалось %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Host != want[i] {
+			t.Errorf("место %d: %s, ожидался %s", i+1, got[i].Host, want[i])
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxHTTPBody — сколько байт ответа читается для проверки содержимого;
+// без проверок столько же читается и отбрасывается
+const maxHTTPBody = 1 << 20
+
+// HTTPCheck — проверки ответа HTTP(S) из конфигурации хоста
+type HTTPCheck struct {
+	ExpectStatus int            // Ожидаемый код ответа; 0 — любой код ниже 400
+	BodyContains string         // Подстрока, которая должна быть в ответе
+	BodyRegex    *regexp.Regexp // Регулярное выражение, которому должен соответствовать ответ
+}
+
+// needBody сообщает, нужно ли читать тело ответа для проверки
+func (c HTTPCheck) needBody() bool {
+	return c.BodyContains != "" || c.BodyRegex != nil
+}
+
+// check проверяет код и содержимое ответа и возвращает причину неудачи
+func (c HTTPCheck) check(code int, body []byte) (string, error) {
+	if c.ExpectStatus != 0 && code != c.ExpectStatus {
+		return failStatus, fmt.Errorf("код ответа %d, ожидался %d", code, c.ExpectStatus)
+	}
+	if c.ExpectStatus == 0 && code >= 400 {
+		return failStatus, fmt.Errorf("код ответа %d", code)
+	}
+	if c.BodyContains != "" && !strings.Contains(string(body), c.BodyContains) {
+		return failBody, fmt.Errorf("в ответе нет %q", c.BodyContains)
+	}
+	if c.BodyRegex != nil && !c.BodyRegex.Match(body) {
+		return failBody, fmt.Errorf("ответ не соответствует %q", c.BodyRegex.String())
+	}
+	return "", nil
+}
+
+// httpPhases — длительность этапов одного HTTP-запроса
+type httpPhases struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration // От отправки запроса до первого байта ответа
+	Total   time.Duration
+
+	TLSState *tls.ConnectionState // Для https: состояние соединения после рукопожатия
+}
+
+// traceRequest добавляет к запросу httptrace, заполняющий phases
+func traceRequest(req *http.Request, phases *httpPhases) *http.Request {
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { phases.DNS = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			phases.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			phases.TLS = time.Since(tlsStart)
+			if err == nil {
+				phases.TLSState = &state
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() {
+			phases.TTFB = time.Since(wroteRequest)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// httpProber выполняет запрос GET и измеряет этапы: разрешение имени,
+// TCP-соединение, TLS-рукопожатие, время до первого байта и общее время.
+// Попытка считается успешной, если код и содержимое ответа прошли проверки.
+type httpProber struct {
+	roots *x509.CertPool // Корневые сертификаты для проверки сервера; nil — системные
+}
+
+func (p httpProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	// Без keep-alive каждая попытка заново устанавливает соединение.
+	// Для записи с семейством соединение устанавливается только по нему,
+	// для записи с интерфейсом — с его адреса.
+	dialer, err := sourceDialer(t.Interface, t.addrFamily(), "tcp", 0)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   &tls.Config{RootCAs: p.roots},
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, familyNetwork(network, t.Family), addr)
+			},
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	var sum httpPhases
+	var tlsStats *TLSStats
+	stats := &HTTPStats{}
+	responses := 0
+
+	results := make([]pingResult, 0, opts.Count)
+	for seq := 1; seq <= opts.Count && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		result := pingResult{Seq: seq}
+		phases, code, fail, err := httpGet(ctx, client, t, start)
+		var certErr *tls.CertificateVerificationError
+		switch {
+		case phases.TLSState != nil:
+			tlsStats = tlsStatsFromState(phases.TLSState, opts.CertWarnDays, time.Now())
+			tlsStats.Handshake = durationMs(phases.TLS)
+		case errors.As(err, &certErr):
+			// Сведения о недействительном сертификате берём из ошибки проверки
+			tlsStats = tlsStatsFromCerts(certErr.UnverifiedCertificates, opts.CertWarnDays, time.Now())
+			tlsStats.Error = certErr.Err.Error()
+		}
+		if code != 0 {
+			// Ответ получен: этапы учитываем, даже если он не прошёл проверки
+			stats.StatusCode = code
+			sum.DNS += phases.DNS
+			sum.Connect += phases.Connect
+			sum.TLS += phases.TLS
+			sum.TTFB += phases.TTFB
+			sum.Total += phases.Total
+			responses++
+		}
+		if err != nil {
+			result.Fail = fail
+			stats.Error = err.Error()
+		} else {
+			result.RTT = phases.Total
+			result.Received = true
+		}
+		results = append(results, result)
+
+		if seq < opts.Count {
+			sleepUntil(ctx, start.Add(opts.Interval))
+		}
+	}
+
+	// Этапы усредняем по попыткам, на которые получен ответ
+	if responses > 0 {
+		n := time.Duration(responses)
+		stats.DNS = durationMs(sum.DNS / n)
+		stats.Connect = durationMs(sum.Connect / n)
+		stats.TLS = durationMs(sum.TLS / n)
+		stats.TTFB = durationMs(sum.TTFB / n)
+		stats.Total = durationMs(sum.Total / n)
+	}
+	return ProbeReport{Results: results, Mode: "http GET", HTTP: stats, TLS: tlsStats}, nil
+}
+
+// httpGet выполняет один запрос к t.URL и проверяет ответ. Возвращает
+// этапы запроса, код ответа (0 — ответ не получен) и при неудаче её
+// причину и ошибку.
+func httpGet(ctx context.Context, client *http.Client, t Target, start time.Time) (httpPhases, int, string, error) {
+	var phases httpPhases
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL, nil)
+	if err != nil {
+		return phases, 0, failOther, err
+	}
+	resp, err := client.Do(traceRequest(req, &phases))
+	if err != nil {
+		return phases, 0, classifyError(err), err
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if t.HTTP.needBody() {
+		body, err = io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	} else {
+		_, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxHTTPBody))
+	}
+	phases.Total = time.Since(start)
+	if err != nil {
+		return phases, resp.StatusCode, classifyError(err), err
+	}
+	fail, err := t.HTTP.check(resp.StatusCode, body)
+	return phases, resp.StatusCode, fail, err
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// httpTestOptions — две быстрые попытки с запасом по таймауту
+var httpTestOptions = ProbeOptions{Count: 2, Interval: 10 * time.Millisecond, Timeout: 3 * time.Second}
+
+// httpTestTarget разбирает адрес тестового сервера с проверками ответа
+func httpTestTarget(t *testing.T, url string, check HTTPCheck) Target {
+	t.Helper()
+	target, err := parseTarget(url, pingBackendICMP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.HTTP = check
+	return target
+}
+
+// ttfbDelay — задержка ответа тестового сервера, по ней проверяется TTFB
+const ttfbDelay = 30 * time.Millisecond
+
+func newHTTPTestServer(t *testing.T, tls bool) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(ttfbDelay)
+		fmt.Fprint(w, "pong 42")
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("/endless", func(w http.ResponseWriter, r *http.Request) {
+		// Бесконечный ответ: проверка должна прочитать не больше maxHTTPBody
+		chunk := []byte(strings.Repeat("x", 64<<10))
+		for r.Context().Err() == nil {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+		}
+	})
+	var srv *httptest.Server
+	if tls {
+		srv = httptest.NewTLSServer(mux)
+	} else {
+		srv = httptest.NewServer(mux)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPProberPhases(t *testing.T) {
+	srv := newHTTPTestServer(t, false)
+	report, err := httpProber{}.Probe(context.Background(), httpTestTarget(t, srv.URL+"/ok", HTTPCheck{}), httpTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("попыток %d, ожидалось 2", len(report.Results))
+	}
+	for _, r := range report.Results {
+		if !r.Received || r.Fail != "" {
+			t.Errorf("попытка %d не прошла: %q", r.Seq, r.Fail)
+		}
+		if r.RTT < ttfbDelay {
+			t.Errorf("попытка %d: RTT %v меньше задержки сервера %v", r.Seq, r.RTT, ttfbDelay)
+		}
+	}
+
+	h := report.HTTP
+	if h == nil {
+		t.Fatal("нет этапов запроса")
+	}
+	if h.StatusCode != http.StatusOK || h.Error != "" {
+		t.Errorf("код %d, ошибка %q", h.StatusCode, h.Error)
+	}
+	if h.Connect <= 0 {
+		t.Errorf("время соединения %v мс", h.Connect)
+	}
+	if h.TLS != 0 || report.TLS != nil {
+		t.Errorf("для http есть TLS: %v мс, %+v", h.TLS, report.TLS)
+	}
+	if h.TTFB < durationMs(ttfbDelay) {
+		t.Errorf("TTFB %v мс меньше задержки сервера %v", h.TTFB, ttfbDelay)
+	}
+	if h.Total < h.TTFB || h.Total < h.Connect {
+		t.Errorf("общее время %v мс меньше этапов: соединение %v, TTFB %v", h.Total, h.Connect, h.TTFB)
+	}
+}
+
+func TestHTTPProberTLSPhases(t *testing.T) {
+	srv := newHTTPTestServer(t, true)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	report, err := httpProber{roots: roots}.Probe(context.Background(), httpTestTarget(t, srv.URL+"/ok", HTTPCheck{}), httpTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range report.Results {
+		if !r.Received {
+			t.Fatalf("попытка %d не прошла: %q, %s", r.Seq, r.Fail, report.HTTP.Error)
+		}
+	}
+	if report.HTTP.TLS <= 0 {
+		t.Errorf("время TLS-рукопожатия %v мс", report.HTTP.TLS)
+	}
+	if report.HTTP.Total < report.HTTP.TLS+report.HTTP.TTFB {
+		t.Errorf("общее время %v мс меньше суммы TLS %v и TTFB %v", report.HTTP.Total, report.HTTP.TLS, report.HTTP.TTFB)
+	}
+	if report.TLS == nil || report.TLS.Error != "" || len(report.TLS.Chain) == 0 || report.TLS.Version == "" {
+		t.Errorf("сведения о TLS: %+v", report.TLS)
+	}
+}
+
+func TestHTTPProberUntrustedCertificate(t *testing.T) {
+	srv := newHTTPTestServer(t, true)
+	report, err := httpProber{}.Probe(context.Background(), httpTestTarget(t, srv.URL+"/ok", HTTPCheck{}), httpTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range report.Results {
+		if r.Received || r.Fail != failCert {
+			t.Errorf("попытка %d: получен %v, причина %q; ожидалось %q", r.Seq, r.Received, r.Fail, failCert)
+		}
+	}
+	if report.TLS == nil || report.TLS.Error == "" || len(report.TLS.Chain) == 0 {
+		t.Errorf("сведения о недействительном сертификате: %+v", report.TLS)
+	}
+}
+
+func TestHTTPProberChecks(t *testing.T) {
+	srv := newHTTPTestServer(t, false)
+	tests := []struct {
+		name     string
+		path     string
+		check    HTTPCheck
+		wantFail string
+		wantCode int
+		wantErr  string
+	}{
+		{"ожидаемый код", "/ok", HTTPCheck{ExpectStatus: 200}, "", 200, ""},
+		{"неожиданный код", "/ok", HTTPCheck{ExpectStatus: 204}, failStatus, 200, "код ответа 200, ожидался 204"},
+		{"ошибка сервера без expect_status", "/unavailable", HTTPCheck{}, failStatus, 503, "код ответа 503"},
+		{"ожидаемая ошибка сервера", "/unavailable", HTTPCheck{ExpectStatus: 503}, "", 503, ""},
+		{"body_contains найден", "/ok", HTTPCheck{BodyContains: "pong"}, "", 200, ""},
+		{"body_contains не найден", "/ok", HTTPCheck{BodyContains: "ping"}, failBody, 200, `в ответе нет "ping"`},
+		{"body_regex совпал", "/ok", HTTPCheck{BodyRegex: regexp.MustCompile(`^pong \d+$`)}, "", 200, ""},
+		{"body_regex не совпал", "/ok", HTTPCheck{BodyRegex: regexp.MustCompile(`^ping`)}, failBody, 200, `ответ не соответствует "^ping"`},
+		{"бесконечный ответ без проверок", "/endless", HTTPCheck{}, "", 200, ""},
+		{"бесконечный ответ с проверкой", "/endless", HTTPCheck{BodyContains: "y"}, failBody, 200, `в ответе нет "y"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := httpTestOptions
+			opts.Count = 1
+			report, err := httpProber{}.Probe(context.Background(), httpTestTarget(t, srv.URL+tt.path, tt.check), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := report.Results[0]
+			if r.Fail != tt.wantFail || r.Received != (tt.wantFail == "") {
+				t.Errorf("получен %v, причина %q; ожидалась %q", r.Received, r.Fail, tt.wantFail)
+			}
+			if report.HTTP.StatusCode != tt.wantCode {
+				t.Errorf("код %d, ожидался %d", report.HTTP.StatusCode, tt.wantCode)
+			}
+			if report.HTTP.Error != tt.wantErr {
+				t.Errorf("ошибка %q, ожидалась %q", report.HTTP.Error, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPProberRefused(t *testing.T) {
+	srv := newHTTPTestServer(t, false)
+	url := srv.URL + "/ok"
+	srv.Close()
+
+	report, err := httpProber{}.Probe(context.Background(), httpTestTarget(t, url, HTTPCheck{}), httpTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range report.Results {
+		if r.Received || r.Fail != failRefused {
+			t.Errorf("попытка %d: получен %v, причина %q; ожидалось %q", r.Seq, r.Received, r.Fail, failRefused)
+		}
+	}
+	if report.HTTP.StatusCode != 0 || report.HTTP.Total != 0 {
+		t.Errorf("без ответа есть код или этапы: %+v", report.HTTP)
+	}
+}
+
+func TestHTTPProberTimeout(t *testing.T) {
+	srv := newHTTPTestServer(t, false)
+	opts := httpTestOptions
+	opts.Count = 1
+	opts.Timeout = ttfbDelay / 3
+
+	report, err := httpProber{}.Probe(context.Background(), httpTestTarget(t, srv.URL+"/ok", HTTPCheck{}), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := report.Results[0]; r.Received || r.Fail != failTimeout {
+		t.Errorf("получен %v, причина %q; ожидалось %q", r.Received, r.Fail, failTimeout)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// tcpProber измеряет время установки TCP-соединения (SYN — SYN/ACK) с
+// портом хоста. Имя разрешается один раз до серии, поэтому время DNS в
+// RTT не входит. Неудачные попытки различаются по причине: таймаут,
+// отказ в соединении (RST на SYN), сброс соединения, недоступность хоста.
+type tcpProber struct{}
+
+func (tcpProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	port := t.Port
+	if port == "" {
+		if opts.TCPPort <= 0 {
+			return ProbeReport{}, fmt.Errorf("для %s не указан порт", t.Raw)
+		}
+		port = strconv.Itoa(opts.TCPPort)
+	}
+
+	// Имя разрешаем заранее, ошибку DNS записываем во все попытки серии.
+	// Для записи с семейством берём только адреса этого семейства.
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, t.Host)
+	ips = filterFamily(ips, t.Family)
+	if err != nil || len(ips) == 0 {
+		results := lostResults(opts.Count)
+		for i := range results {
+			results[i].Fail = failDNS
+		}
+		return ProbeReport{Results: results, Mode: "tcp connect " + net.JoinHostPort(t.Host, port)}, nil
+	}
+	addr := net.JoinHostPort(ips[0].String(), port)
+	dialer, err := sourceDialer(t.Interface, ipFamily(ips[0].IP), "tcp", opts.Timeout)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+
+	results := make([]pingResult, 0, opts.Count)
+	for seq := 1; seq <= opts.Count && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		result := pingResult{Seq: seq}
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			result.Fail = classifyError(err)
+		} else {
+			result.RTT = time.Since(start)
+			result.Received = true
+			conn.Close()
+		}
+		results = append(results, result)
+
+		if seq < opts.Count {
+			sleepUntil(ctx, start.Add(opts.Interval))
+		}
+	}
+	return ProbeReport{Results: results, Mode: "tcp connect " + addr}, nil
+}
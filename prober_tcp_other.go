@@ -0,0 +1,12 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// Коды ошибок сокетов для классификации неудачных подключений
+var (
+	errnoConnRefused = []syscall.Errno{syscall.ECONNREFUSED}
+	errnoConnReset   = []syscall.Errno{syscall.ECONNRESET, syscall.ECONNABORTED}
+	errnoUnreachable = []syscall.Errno{syscall.EHOSTUNREACH, syscall.ENETUNREACH}
+)
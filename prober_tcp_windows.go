@@ -0,0 +1,16 @@
+//go:build windows
+
+package main
+
+import (
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// Коды ошибок сокетов Windows для классификации неудачных подключений
+var (
+	errnoConnRefused = []syscall.Errno{windows.WSAECONNREFUSED}
+	errnoConnReset   = []syscall.Errno{windows.WSAECONNRESET, windows.WSAECONNABORTED}
+	errnoUnreachable = []syscall.Errno{windows.WSAEHOSTUNREACH, windows.WSAENETUNREACH}
+)
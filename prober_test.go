@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Ошибка разрешения имени не переводит встроенный пинг на утилиту ping
+// и учитывается как ошибка DNS
+func TestICMPProberResolveError(t *testing.T) {
+	target, err := parseTarget("nonexistent.invalid", pingBackendICMP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &icmpProber{}
+	_, err = p.Probe(context.Background(), target, ProbeOptions{Count: 1, Timeout: time.Second})
+	if err == nil {
+		t.Fatal("имя .invalid разрешилось")
+	}
+	if reason := classifyError(err); reason != failDNS {
+		t.Errorf("причина %q, ожидалась %q: %v", reason, failDNS, err)
+	}
+	if p.fallback {
+		t.Error("после ошибки разрешения имени включена утилита ping")
+	}
+}
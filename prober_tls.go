@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// tlsProber измеряет время TLS-рукопожатия с портом хоста (по умолчанию
+// 443) и собирает сведения о сертификате. В RTT входит только рукопожатие,
+// без разрешения имени и установки TCP-соединения. Рукопожатие с
+// недействительным сертификатом считается потерей, но сведения о
+// сертификате, в том числе срок действия, всё равно сохраняются.
+type tlsProber struct {
+	roots *x509.CertPool // Корневые сертификаты для проверки сервера; nil — системные
+}
+
+func (p tlsProber) Probe(ctx context.Context, t Target, opts ProbeOptions) (ProbeReport, error) {
+	port := t.Port
+	if port == "" {
+		port = "443"
+	}
+	addr := net.JoinHostPort(t.Host, port)
+	dialer, err := sourceDialer(t.Interface, t.addrFamily(), "tcp", opts.Timeout)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+
+	var stats *TLSStats
+	results := make([]pingResult, 0, opts.Count)
+	for seq := 1; seq <= opts.Count && ctx.Err() == nil; seq++ {
+		start := time.Now()
+		result := pingResult{Seq: seq}
+		state, rtt, err := tlsHandshake(ctx, dialer, familyNetwork("tcp", t.Family), addr, t.Host, opts.Timeout)
+		if err != nil {
+			result.Fail = classifyError(err)
+			if stats == nil {
+				stats = &TLSStats{}
+			}
+			stats.Error = err.Error()
+		} else {
+			now := time.Now()
+			stats = tlsStatsFromState(state, opts.CertWarnDays, now)
+			stats.Handshake = durationMs(rtt)
+			if err := verifyCerts(state.PeerCertificates, p.roots, t.Host, now); err != nil {
+				result.Fail = failCert
+				stats.Error = err.Error()
+			} else {
+				result.RTT = rtt
+				result.Received = true
+			}
+		}
+		results = append(results, result)
+
+		if seq < opts.Count {
+			sleepUntil(ctx, start.Add(opts.Interval))
+		}
+	}
+	return ProbeReport{Results: results, Mode: "TLS-рукопожатие " + addr, TLS: stats}, nil
+}
+
+// tlsHandshake устанавливает TCP-соединение с addr по сети network ("tcp",
+// "tcp4" или "tcp6") и выполняет TLS-рукопожатие без проверки сертификата,
+// её выполняет verifyCerts. Возвращает состояние соединения и время рукопожатия.
+func tlsHandshake(ctx context.Context, dialer *net.Dialer, network, addr, serverName string, timeout time.Duration) (*tls.ConnectionState, time.Duration, error) {
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true})
+	start := time.Now()
+	if err := client.HandshakeContext(ctx); err != nil {
+		return nil, 0, err
+	}
+	rtt := time.Since(start)
+	state := client.ConnectionState()
+	return &state, rtt, nil
+}
+
+// tlsStatsFromState собирает сведения о TLS-соединении и его сертификатах
+func tlsStatsFromState(state *tls.ConnectionState, warnDays int, now time.Time) *TLSStats {
+	stats := tlsStatsFromCerts(state.PeerCertificates, warnDays, now)
+	stats.Version = tls.VersionName(state.Version)
+	stats.Cipher = tls.CipherSuiteName(state.CipherSuite)
+	return stats
+}
+
+// tlsStatsFromCerts собирает сведения о цепочке сертификатов. Срок
+// действия цепочки определяет сертификат, который истекает первым.
+func tlsStatsFromCerts(certs []*x509.Certificate, warnDays int, now time.Time) *TLSStats {
+	stats := &TLSStats{}
+	if len(certs) == 0 {
+		stats.Error = "сервер не предъявил сертификат"
+		return stats
+	}
+
+	stats.NotAfter = certs[0].NotAfter
+	for _, cert := range certs {
+		stats.Chain = append(stats.Chain, TLSCert{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			NotAfter: cert.NotAfter,
+		})
+		if cert.NotAfter.Before(stats.NotAfter) {
+			stats.NotAfter = cert.NotAfter
+		}
+	}
+	stats.DaysLeft = int(math.Floor(stats.NotAfter.Sub(now).Hours() / 24))
+	stats.Warning = stats.DaysLeft < warnDays
+	return stats
+}
+
+// verifyCerts проверяет цепочку сертификатов сервера host по корневым
+// сертификатам roots; nil — системные
+func verifyCerts(certs []*x509.Certificate, roots *x509.CertPool, host string, now time.Time) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("сервер не предъявил сертификат")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	if err != nil {
+		return fmt.Errorf("сертификат не прошёл проверку: %v", err)
+	}
+	return nil
+}
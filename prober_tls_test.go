@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// tlsTestTarget возвращает запись tls:// для адреса тестового сервера
+func tlsTestTarget(t *testing.T, srv *httptest.Server) Target {
+	t.Helper()
+	return httpTestTarget(t, "tls://"+srv.Listener.Addr().String(), HTTPCheck{})
+}
+
+// quietTLSServer отключает лог сервера: проба закрывает соединение сразу
+// после рукопожатия, и сервер сообщает об этом как об ошибке
+func quietTLSServer(t *testing.T) {
+	t.Helper()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func TestTLSProber(t *testing.T) {
+	quietTLSServer(t)
+	srv := newHTTPTestServer(t, true)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	report, err := tlsProber{roots: roots}.Probe(context.Background(), tlsTestTarget(t, srv), httpTestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != httpTestOptions.Count {
+		t.Fatalf("попыток %d", len(report.Results))
+	}
+	for _, r := range report.Results {
+		if !r.Received || r.RTT <= 0 {
+			t.Fatalf("попытка %d не прошла: %q, %s", r.Seq, r.Fail, report.TLS.Error)
+		}
+	}
+	s := report.TLS
+	if s == nil || s.Error != "" || s.Handshake <= 0 || s.Version == "" || s.Cipher == "" || len(s.Chain) == 0 {
+		t.Fatalf("сведения о TLS: %+v", s)
+	}
+	if !s.NotAfter.Equal(srv.Certificate().NotAfter) || s.DaysLeft <= 0 || s.Warning {
+		t.Errorf("срок действия %v, осталось %d дней, предупреждение %v", s.NotAfter, s.DaysLeft, s.Warning)
+	}
+}
+
+func TestTLSProberFailures(t *testing.T) {
+	quietTLSServer(t)
+	srv := newHTTPTestServer(t, true)
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	plain := newHTTPTestServer(t, false)
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Сертификат тестового сервера выписан на example.com и 127.0.0.1
+	wrongName := httpTestTarget(t, "tls://localhost:"+port, HTTPCheck{})
+
+	for _, tt := range []struct {
+		name   string
+		prober tlsProber
+		target Target
+		fail   string
+		cert   bool // Сведения о сертификате сохранены несмотря на ошибку
+	}{
+		{"недоверенный сертификат", tlsProber{}, tlsTestTarget(t, srv), failCert, true},
+		{"чужое имя", tlsProber{roots: roots}, wrongName, failCert, true},
+		{"сервер без TLS", tlsProber{roots: roots}, tlsTestTarget(t, plain), failOther, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := tt.prober.Probe(context.Background(), tt.target, httpTestOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range report.Results {
+				if r.Received || r.Fail != tt.fail {
+					t.Errorf("попытка %d: получено %v, причина %q, ожидалась потеря %q", r.Seq, r.Received, r.Fail, tt.fail)
+				}
+			}
+			if report.TLS == nil || report.TLS.Error == "" {
+				t.Fatalf("нет ошибки в сведениях о TLS: %+v", report.TLS)
+			}
+			if got := len(report.TLS.Chain) > 0; got != tt.cert {
+				t.Errorf("цепочка сертификатов: %+v", report.TLS.Chain)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// reportFileName — HTML-отчёт о сессии в каталоге логов
+const reportFileName = "report.html"
+
+// outage — период, когда хост не ответил ни на один пакет
+type outage struct {
+	Host       string
+	Name       string
+	Start, End time.Time
+}
+
+// Duration возвращает длительность недоступности
+func (o outage) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+// findOutages возвращает периоды недоступности хостов: подряд идущие записи
+// без единого ответа. Конец периода — начало следующей записи.
+func findOutages(series []graphSeries) []outage {
+	var outages []outage
+	for _, s := range series {
+		var current *outage
+		for i, sample := range s.Samples {
+			if sample.Sent == 0 || sample.Received > 0 {
+				current = nil
+				continue
+			}
+			end := sample.Time.Add(sampleSpan(s.Samples, i))
+			if current != nil {
+				current.End = end
+				continue
+			}
+			outages = append(outages, outage{Host: s.Host, Name: s.Name, Start: sample.Time, End: end})
+			current = &outages[len(outages)-1]
+		}
+	}
+	return outages
+}
+
+// mtrLogHeaderRe — заголовок трассировки в mtr_results.log (см. updateMTRStats)
+var mtrLogHeaderRe = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) Результаты MTR до (.+):$`)
+
+// mtrLogEntry — трассировка из mtr_results.log
+type mtrLogEntry struct {
+	Time   time.Time
+	Target string
+	Output string
+}
+
+// readMTRLog возвращает трассировки из mtr_results.log за период [from, to]
+func readMTRLog(logDir string, from, to time.Time) ([]mtrLogEntry, error) {
+	data, err := os.ReadFile(filepath.Join(logDir, "mtr_results.log"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка при чтении файла логов MTR: %v", err)
+	}
+
+	var entries []mtrLogEntry
+	var current *mtrLogEntry
+	var output []string
+	flush := func() {
+		if current != nil {
+			current.Output = strings.TrimSpace(strings.Join(output, "\n"))
+			if !current.Time.Before(from.Truncate(time.Second)) && !current.Time.After(to) {
+				entries = append(entries, *current)
+			}
+		}
+		current, output = nil, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := mtrLogHeaderRe.FindStringSubmatch(line); m != nil {
+			flush()
+			t, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local)
+			if err != nil {
+				continue
+			}
+			current = &mtrLogEntry{Time: t, Target: m[2]}
+			continue
+		}
+		if current != nil {
+			output = append(output, line)
+		}
+	}
+	flush()
+	return entries, nil
+}
+
+// reportHost — строка сводной таблицы отчёта
+type reportHost struct {
+	Name      string
+	Group     string
+	Sent      int
+	Received  int
+	Loss      float64
+	Min       float64
+	Avg       float64
+	Max       float64
+	P95       float64
+	Jitter    float64
+	Outages   int
+	Downtime  time.Duration
+	Failures  string
+	LossClass string // Класс CSS для подсветки потерь
+}
+
+// reportChart — график хоста для отчёта
+type reportChart struct {
+	Name string
+	SVG  template.HTML
+}
+
+// reportData — данные шаблона отчёта
+type reportData struct {
+	From, To  time.Time
+	Generated time.Time
+	Hosts     []reportHost
+	History   bool   // Графики и недоступность построены по истории измерений, а не по памяти
+	HistError string // Ошибка открытия базы, если история включена, но не ведётся
+	Overview  template.HTML
+	Charts    []reportChart
+	Timeline  template.HTML
+	Outages   []outage
+	MTR       []mtrLogEntry
+}
+
+// writeHTMLReport сохраняет в logDir отчёт о сессии [from, to]: сводную
+// таблицу, графики RTT и потерь, периоды недоступности и трассировки из
+// mtr_results.log. Всё встроено в один файл, внешних ресурсов нет.
+// series — записи хостов за сессию: из истории измерений, если fromHistory,
+// иначе результаты циклов, сохранённые монитором в памяти; historyErr —
+// почему не ведётся включённая история, nil если она отключена.
+func writeHTMLReport(logDir string, snapshot []PingStats, series []graphSeries, fromHistory bool, historyErr error, from, to time.Time) error {
+	if logDir == "" {
+		return nil
+	}
+	outages := findOutages(series)
+	mtr, err := readMTRLog(logDir, from, to)
+	if err != nil {
+		return err
+	}
+
+	data := reportData{From: from, To: to, Generated: time.Now(), History: fromHistory, Outages: outages, MTR: mtr}
+	if !fromHistory && historyErr != nil {
+		data.HistError = historyErr.Error()
+	}
+	for i := range snapshot {
+		s := &snapshot[i]
+		row := reportHost{
+			Name:     s.DisplayName(),
+			Group:    s.Group,
+			Sent:     s.TotalSent,
+			Received: s.TotalReceived,
+			Loss:     s.TotalLoss,
+			Min:      s.LifetimeMin,
+			Avg:      s.RunningMean,
+			Max:      s.LifetimeMax,
+			P95:      s.P95,
+			Jitter:   s.Jitter,
+			Failures: formatFailures(s.TotalFailures),
+		}
+		for _, o := range outages {
+			if o.Host == s.Host {
+				row.Outages++
+				row.Downtime += o.Duration()
+			}
+		}
+		switch {
+		case row.Loss >= 10:
+			row.LossClass = "bad"
+		case row.Loss > 0:
+			row.LossClass = "warn"
+		}
+		data.Hosts = append(data.Hosts, row)
+	}
+
+	if len(series) > 0 {
+		names := make([]string, len(series))
+		samples := make([][]historySample, len(series))
+		for i, s := range series {
+			names[i], samples[i] = s.Name, s.Samples
+			svg, err := chartSVG(hostChart(s.Name, s.Samples, from, to), hostGraphWidth, hostGraphHeight)
+			if err != nil {
+				return fmt.Errorf("ошибка при построении графика %s: %v", s.Name, err)
+			}
+			data.Charts = append(data.Charts, reportChart{Name: s.Name, SVG: inlineSVG(svg)})
+		}
+		svg, err := chartSVG(overviewChart(names, samples, from, to), overviewGraphWidth, overviewGraphHeight)
+		if err != nil {
+			return fmt.Errorf("ошибка при построении общего графика: %v", err)
+		}
+		data.Overview = inlineSVG(svg)
+		if svg, err = outageTimelineSVG(series, outages, from, to); err != nil {
+			return fmt.Errorf("ошибка при построении шкалы недоступности: %v", err)
+		}
+		data.Timeline = inlineSVG(svg)
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, data); err != nil {
+		return fmt.Errorf("ошибка при формировании HTML-отчёта: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, reportFileName), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("ошибка при записи HTML-отчёта: %v", err)
+	}
+	return nil
+}
+
+// Размеры шкалы недоступности, пикселей
+const (
+	timelineWidth = 1100
+	timelineRow   = 22
+)
+
+// outageTimelineSVG рисует шкалу времени сессии со строкой на каждый хост,
+// периоды недоступности закрашены красным
+func outageTimelineSVG(series []graphSeries, outages []outage, from, to time.Time) ([]byte, error) {
+	p := outageTimeline(series, outages, from, to)
+	return plotSVG(func(c draw.Canvas) error {
+		p.Draw(chartPadding(c))
+		return nil
+	}, timelineWidth, timelineRow*len(series)+40)
+}
+
+// outageTimeline строит шкалу недоступности: хосты идут сверху вниз
+// в порядке series, ось X — секунды от from
+func outageTimeline(series []graphSeries, outages []outage, from, to time.Time) *plot.Plot {
+	if !to.After(from) {
+		to = from.Add(time.Minute)
+	}
+	x := func(t time.Time) float64 {
+		return t.Sub(from).Seconds()
+	}
+
+	p := newChartPlot("")
+	p.X.Tick.Marker = timeAxisTicks(from, to)
+	row := make(map[string]float64, len(series))
+	var names plot.ConstantTicks
+	for i, s := range series {
+		y := float64(len(series) - 1 - i)
+		row[s.Host] = y
+		names = append(names, plot.Tick{Value: y, Label: s.Name})
+		p.Add(chartRect(0, x(to), y-0.3, y+0.3, chartGridColor))
+	}
+	p.Y.Tick.Marker = names
+	p.Y.Tick.Length = 0
+	p.Y.LineStyle.Width = 0
+	for _, o := range outages {
+		y := row[o.Host]
+		p.Add(chartRect(x(o.Start), x(o.End), y-0.3, y+0.3, chartLossColor))
+	}
+	p.X.Min, p.X.Max = 0, x(to)
+	p.Y.Min, p.Y.Max = -0.5, float64(len(series))-0.5
+	return p
+}
+
+// inlineSVG возвращает SVG-документ без XML-пролога для вставки в HTML
+func inlineSVG(svg []byte) template.HTML {
+	if i := bytes.Index(svg, []byte("<svg")); i >= 0 {
+		svg = svg[i:]
+	}
+	return template.HTML(svg)
+}
+
+// reportFuncs — функции шаблона отчёта
+var reportFuncs = template.FuncMap{
+	"datetime": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"duration": func(d time.Duration) string { return d.Round(time.Second).String() },
+	"ms":       func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"pct":      func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+}
+
+// reportTemplate — HTML-отчёт. Стили встроены, графики — элементы <svg>,
+// поэтому файл открывается без сети.
+var reportTemplate = template.Must(template.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Отчёт pingstats {{datetime .From}} — {{datetime .To}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; color: #282828; margin: 24px; }
+h1 { font-size: 22px; }
+h2 { font-size: 18px; margin-top: 32px; border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+h3 { font-size: 15px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th { background: #f3f3f3; }
+td.name, th.name { text-align: left; }
+tr.warn td.loss { background: #fff3cd; }
+tr.bad td.loss { background: #f8d7da; }
+.note { color: #777; }
+.chart { margin: 12px 0; overflow-x: auto; }
+pre { background: #f6f6f6; border: 1px solid #ddd; padding: 8px; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Отчёт о мониторинге</h1>
+<p>Сессия: {{datetime .From}} — {{datetime .To}} ({{duration (.To.Sub .From)}}). Отчёт создан {{datetime .Generated}}.</p>
+
+<h2>Сводка по хостам</h2>
+{{if .Hosts}}<table>
+<tr><th class="name">Хост</th><th class="name">Группа</th><th>Отправлено/получено</th><th>Потери</th><th>Мин. RTT, мс</th><th>Сред. RTT, мс</th><th>Макс. RTT, мс</th><th>P95, мс</th><th>Джиттер, мс</th><th>Недоступность</th><th class="name">Причины потерь</th></tr>
+{{range .Hosts}}<tr class="{{.LossClass}}"><td class="name">{{.Name}}</td><td class="name">{{.Group}}</td><td>{{.Sent}}/{{.Received}}</td><td class="loss">{{pct .Loss}}</td><td>{{ms .Min}}</td><td>{{ms .Avg}}</td><td>{{ms .Max}}</td><td>{{ms .P95}}</td><td>{{ms .Jitter}}</td><td>{{if .Outages}}{{.Outages}} ({{duration .Downtime}}){{else}}—{{end}}</td><td class="name">{{.Failures}}</td></tr>
+{{end}}</table>
+{{else}}<p class="note">Хосты не проверялись.</p>
+{{end}}
+<h2>Графики RTT и потерь</h2>
+{{if not .History}}<p class="note">{{if .HistError}}История измерений недоступна: {{.HistError}}.{{else}}История измерений отключена ([history] enabled = false).{{end}} Графики и периоды недоступности построены по результатам циклов сессии, сохранённым в памяти.</p>
+{{end}}{{if .Charts}}<div class="chart">{{.Overview}}</div>
+{{range .Charts}}<h3>{{.Name}}</h3>
+<div class="chart">{{.SVG}}</div>
+{{end}}{{else}}<p class="note">За сессию нет измерений.</p>
+{{end}}
+<h2>Недоступность</h2>
+{{if .Charts}}<div class="chart">{{.Timeline}}</div>
+{{if .Outages}}<table>
+<tr><th class="name">Хост</th><th class="name">Начало</th><th class="name">Конец</th><th>Длительность</th></tr>
+{{range .Outages}}<tr><td class="name">{{.Name}}</td><td class="name">{{datetime .Start}}</td><td class="name">{{datetime .End}}</td><td>{{duration .Duration}}</td></tr>
+{{end}}</table>
+{{else}}<p class="note">Все хосты были доступны.</p>
+{{end}}{{else}}<p class="note">За сессию нет измерений.</p>
+{{end}}
+<h2>Трассировки MTR</h2>
+{{range .MTR}}<h3>{{datetime .Time}} — {{.Target}}</h3>
+<pre>{{.Output}}</pre>
+{{else}}<p class="note">За сессию трассировок не было.</p>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Отчёт отличает отключённую историю от недоступной, называет причину и
+// без истории строит графики по результатам циклов в памяти
+func TestWriteHTMLReportHistoryState(t *testing.T) {
+	to := time.Date(2026, 5, 1, 19, 0, 0, 0, time.UTC)
+	from := to.Add(-time.Hour)
+	snapshot := []PingStats{{Host: "8.8.8.8", TotalSent: 4, TotalReceived: 4}}
+	samples := []historySample{{Time: from.Add(time.Minute), Cycles: 1, Sent: 4, Received: 4, MinRTT: 1, AvgRTT: 2, MaxRTT: 3}}
+
+	series := []graphSeries{{Host: "8.8.8.8", Name: "8.8.8.8", Samples: samples}}
+	const memoryNote = "Графики и периоды недоступности построены по результатам циклов сессии, сохранённым в памяти."
+
+	tests := []struct {
+		name        string
+		fromHistory bool
+		historyErr  error
+		want        []string
+		notWant     []string
+	}{
+		{"история отключена", false, nil,
+			[]string{"История измерений отключена ([history] enabled = false).", memoryNote, "<svg", "Все хосты были доступны."},
+			[]string{"недоступна"}},
+		{"база не открылась", false, errors.New("база истории history.db занята другим экземпляром программы"),
+			[]string{"История измерений недоступна: база истории history.db занята другим экземпляром программы.", memoryNote, "<svg"},
+			[]string{"отключена"}},
+		{"история ведётся", true, nil,
+			[]string{"<svg", "Все хосты были доступны."},
+			[]string{"отключена", "недоступна:", memoryNote}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := writeHTMLReport(dir, snapshot, series, tt.fromHistory, tt.historyErr, from, to); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, reportFileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			report := string(data)
+			for _, s := range tt.want {
+				if !strings.Contains(report, s) {
+					t.Errorf("в отчёте нет %q", s)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(report, s) {
+					t.Errorf("в отчёте есть %q", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// defaultRoute — маршрут по умолчанию из таблицы маршрутизации ядра
+type defaultRoute struct {
+	Family    string // familyIPv4 или familyIPv6
+	Gateway   net.IP // nil — маршрут через интерфейс без шлюза (например, VPN-туннель)
+	Interface string
+	Metric    int
+}
+
+// gatewayAddr возвращает адрес шлюза для пинга. Link-local шлюз IPv6
+// дополняется зоной интерфейса: fe80::1%eth0.
+func (r defaultRoute) gatewayAddr() string {
+	if r.Gateway == nil {
+		return ""
+	}
+	if r.Gateway.IsLinkLocalUnicast() && r.Gateway.To4() == nil && r.Interface != "" {
+		return r.Gateway.String() + "%" + r.Interface
+	}
+	return r.Gateway.String()
+}
+
+// String описывает маршрут для лога
+func (r defaultRoute) String() string {
+	gateway := "без шлюза"
+	if r.Gateway != nil {
+		gateway = "шлюз " + r.Gateway.String()
+	}
+	return fmt.Sprintf("%s: %s, интерфейс %s, метрика %d", r.Family, gateway, r.Interface, r.Metric)
+}
+
+// getDefaultRoutes возвращает все маршруты по умолчанию IPv4 и IPv6,
+// упорядоченные по семейству и метрике: первым идёт основной маршрут
+func getDefaultRoutes() ([]defaultRoute, error) {
+	routes, err := readDefaultRoutes()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Family != routes[j].Family {
+			return routes[i].Family < routes[j].Family
+		}
+		return routes[i].Metric < routes[j].Metric
+	})
+	return routes, nil
+}
+
+// getDefaultGateways возвращает адреса шлюзов всех маршрутов по умолчанию
+func getDefaultGateways(routes []defaultRoute) ([]string, error) {
+	var gateways []string
+	for _, r := range routes {
+		if addr := r.gatewayAddr(); addr != "" {
+			gateways = append(gateways, addr)
+		}
+	}
+	if len(gateways) == 0 {
+		return nil, fmt.Errorf("шлюз по умолчанию не найден")
+	}
+	return uniqueHosts(gateways), nil
+}
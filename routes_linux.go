@@ -0,0 +1,117 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Флаги маршрутов из linux/route.h
+const (
+	rtfUp     = 0x0001
+	rtfReject = 0x0200
+)
+
+// readDefaultRoutes читает маршруты по умолчанию из /proc/net/route и
+// /proc/net/ipv6_route. Таблицы IPv6 может не быть, если IPv6 отключён.
+func readDefaultRoutes() ([]defaultRoute, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения таблицы маршрутизации: %v", err)
+	}
+	defer f.Close()
+	routes, err := parseProcRoute(f)
+	if err != nil {
+		return nil, err
+	}
+
+	f6, err := os.Open("/proc/net/ipv6_route")
+	if err != nil {
+		return routes, nil
+	}
+	defer f6.Close()
+	routes6, err := parseProcIPv6Route(f6)
+	if err != nil {
+		return nil, err
+	}
+	return append(routes, routes6...), nil
+}
+
+// parseProcRoute разбирает /proc/net/route:
+//
+//	Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+//	eth0  00000000    010200C0 0003 0      0   100    00000000
+//
+// Адреса записаны в шестнадцатеричном виде в порядке байт процессора.
+func parseProcRoute(r io.Reader) ([]defaultRoute, error) {
+	var routes []defaultRoute
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Заголовок
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		route := defaultRoute{Family: familyIPv4, Interface: fields[0], Metric: metric}
+		if gw != 0 {
+			route.Gateway = make(net.IP, net.IPv4len)
+			binary.NativeEndian.PutUint32(route.Gateway, uint32(gw))
+		}
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения таблицы маршрутизации: %v", err)
+	}
+	return routes, nil
+}
+
+// parseProcIPv6Route разбирает /proc/net/ipv6_route:
+//
+//	<сеть> <длина> <источник> <длина> <шлюз> <метрика> <ссылок> <исп.> <флаги> <интерфейс>
+//
+// Адреса, длины, метрика и флаги записаны в шестнадцатеричном виде.
+func parseProcIPv6Route(r io.Reader) ([]defaultRoute, error) {
+	var routes []defaultRoute
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[1] != "00" || strings.Trim(fields[0], "0") != "" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil || flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+		gw, err := hex.DecodeString(fields[4])
+		if err != nil || len(gw) != net.IPv6len {
+			continue
+		}
+		metric, _ := strconv.ParseUint(fields[5], 16, 32)
+		route := defaultRoute{Family: familyIPv6, Interface: fields[9], Metric: int(metric)}
+		if !net.IP(gw).IsUnspecified() {
+			route.Gateway = net.IP(gw)
+		}
+		routes = append(routes, route)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения таблицы маршрутизации IPv6: %v", err)
+	}
+	return routes, nil
+}
@@ -0,0 +1,110 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+// procRouteFixture — /proc/net/route ноутбука с Ethernet, Wi-Fi и OpenVPN:
+// маршрут по умолчанию через tun0 без шлюза, отключённый маршрут и
+// маршрут-заглушка (reject) не учитываются
+const procRouteFixture = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+wlan0	00000000	0100A8C0	0003	0	0	600	00000000	0	0	0
+tun0	00000000	00000000	0001	0	0	50	00000000	0	0	0
+eth0	0001A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+wlan0	0000A8C0	00000000	0001	0	0	600	00FFFFFF	0	0	0
+tun0	0000080A	00000000	0001	0	0	0	0000FFFF	0	0	0
+eth1	00000000	0102A8C0	0002	0	0	200	00000000	0	0	0
+lo	00000000	00000000	0201	0	0	4278198272	00000000	0	0	0
+broken
+`
+
+// procIPv6RouteFixture — /proc/net/ipv6_route той же машины: шлюз по
+// умолчанию link-local на eth0, маршрут по умолчанию через wg0 без шлюза,
+// заглушка по умолчанию на lo и обычные маршруты к сетям
+const procIPv6RouteFixture = `fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000002 00000000 00000001     eth0
+fe800000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000002 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fe800000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 00000200 00000001 00000000 00000001      wg0
+00000000000000000000000000000001 80 00000000000000000000000000000000 00 00000000000000000000000000000000 00000000 00000003 00000000 80200001       lo
+ff000000000000000000000000000000 08 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000004 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 zz 00000400 00000001 00000000 00000003     eth0
+`
+
+// skipBigEndian пропускает тест на процессорах с прямым порядком байт (big-endian):
+// адреса IPv4 в /proc/net/route записаны в порядке байт процессора
+func skipBigEndian(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("таблица снята на процессоре с обратным порядком байт (little-endian)")
+	}
+}
+
+func TestParseProcRoute(t *testing.T) {
+	skipBigEndian(t)
+	routes, err := parseProcRoute(strings.NewReader(procRouteFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []defaultRoute{
+		{Family: familyIPv4, Gateway: net.IPv4(192, 168, 1, 1), Interface: "eth0", Metric: 100},
+		{Family: familyIPv4, Gateway: net.IPv4(192, 168, 0, 1), Interface: "wlan0", Metric: 600},
+		{Family: familyIPv4, Interface: "tun0", Metric: 50},
+	}
+	checkRoutes(t, routes, want)
+}
+
+func TestParseProcIPv6Route(t *testing.T) {
+	routes, err := parseProcIPv6Route(strings.NewReader(procIPv6RouteFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []defaultRoute{
+		{Family: familyIPv6, Gateway: net.ParseIP("fe80::1"), Interface: "eth0", Metric: 1024},
+		{Family: familyIPv6, Interface: "wg0", Metric: 512},
+	}
+	checkRoutes(t, routes, want)
+
+	// Link-local шлюз пингуется с зоной интерфейса
+	if addr := routes[0].gatewayAddr(); addr != "fe80::1%eth0" {
+		t.Errorf("адрес шлюза %q, ожидался fe80::1%%eth0", addr)
+	}
+	if addr := routes[1].gatewayAddr(); addr != "" {
+		t.Errorf("адрес шлюза маршрута без шлюза %q", addr)
+	}
+}
+
+func TestParseProcRouteEmpty(t *testing.T) {
+	for name, parse := range map[string]func(string) ([]defaultRoute, error){
+		"route":      func(s string) ([]defaultRoute, error) { return parseProcRoute(strings.NewReader(s)) },
+		"ipv6_route": func(s string) ([]defaultRoute, error) { return parseProcIPv6Route(strings.NewReader(s)) },
+	} {
+		for _, input := range []string{"", "Iface\tDestination\tGateway\n"} {
+			routes, err := parse(input)
+			if err != nil || len(routes) != 0 {
+				t.Errorf("%s %q: %v, %v", name, input, routes, err)
+			}
+		}
+	}
+}
+
+func checkRoutes(t *testing.T, got, want []defaultRoute) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("маршрутов %d, ожидалось %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Family != w.Family || g.Interface != w.Interface || g.Metric != w.Metric || !g.Gateway.Equal(w.Gateway) {
+			t.Errorf("маршрут %d: %v, ожидался %v", i, g, w)
+		}
+		if (g.Gateway == nil) != (w.Gateway == nil) {
+			t.Errorf("маршрут %d: шлюз %v, ожидался %v", i, g.Gateway, w.Gateway)
+		}
+	}
+}
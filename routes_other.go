@@ -0,0 +1,13 @@
+//go:build !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readDefaultRoutes не поддерживается вне Linux и Windows
+func readDefaultRoutes() ([]defaultRoute, error) {
+	return nil, fmt.Errorf("чтение таблицы маршрутизации не поддерживается в %s", runtime.GOOS)
+}
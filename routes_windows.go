@@ -0,0 +1,177 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// GetIpForwardTable2 и FreeMibTable из iphlpapi.dll: в используемой версии
+// golang.org/x/sys обёрток для них ещё нет
+var (
+	modiphlpapi            = windows.NewLazySystemDLL("iphlpapi.dll")
+	procGetIpForwardTable2 = modiphlpapi.NewProc("GetIpForwardTable2")
+	procFreeMibTable       = modiphlpapi.NewProc("FreeMibTable")
+)
+
+// ipAddressPrefix — IP_ADDRESS_PREFIX. Объединение SOCKADDR_INET
+// представлено самым большим вариантом — SOCKADDR_IN6.
+type ipAddressPrefix struct {
+	Prefix       windows.RawSockaddrInet6
+	PrefixLength uint8
+}
+
+// mibIPForwardRow2 — MIB_IPFORWARD_ROW2, строка таблицы маршрутизации
+type mibIPForwardRow2 struct {
+	InterfaceLuid        uint64
+	InterfaceIndex       uint32
+	DestinationPrefix    ipAddressPrefix
+	NextHop              windows.RawSockaddrInet6
+	SitePrefixLength     uint8
+	ValidLifetime        uint32
+	PreferredLifetime    uint32
+	Metric               uint32
+	Protocol             uint32
+	Loopback             uint8
+	AutoconfigureAddress uint8
+	Publish              uint8
+	Immortal             uint8
+	Age                  uint32
+	Origin               uint32
+}
+
+// mibIPForwardTableRows — смещение массива строк в MIB_IPFORWARD_TABLE2:
+// после ULONG NumEntries строки выравниваются по 8 байт (NET_LUID)
+const mibIPForwardTableRows = 8
+
+// readDefaultRoutes читает маршруты по умолчанию (префикс длины 0) из
+// таблицы маршрутизации через GetIpForwardTable2. В отличие от шлюзов
+// адаптеров, сюда попадают и маршруты без шлюза через VPN- и PPP-интерфейсы.
+// Метрика — сумма метрики маршрута и метрики интерфейса, как в route print:
+// по ней Windows выбирает основной маршрут.
+func readDefaultRoutes() ([]defaultRoute, error) {
+	adapters, err := upAdapters()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ipForwardTable(windows.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения таблицы маршрутизации: %v", err)
+	}
+
+	var routes []defaultRoute
+	for i := range rows {
+		row := &rows[i]
+		if row.DestinationPrefix.PrefixLength != 0 || row.Loopback != 0 {
+			continue
+		}
+		family := sockaddrFamily(&row.DestinationPrefix.Prefix)
+		if family == "" {
+			continue
+		}
+		adapter, ok := adapters[adapterKey{family, row.InterfaceIndex}]
+		if !ok {
+			continue
+		}
+		route := defaultRoute{Family: family, Interface: adapter.name, Metric: int(row.Metric) + adapter.metric}
+		if ip := sockaddrIP(&row.NextHop); ip != nil && !ip.IsUnspecified() {
+			route.Gateway = ip
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// ipForwardTable возвращает копию таблицы маршрутизации семейства family
+func ipForwardTable(family uint16) ([]mibIPForwardRow2, error) {
+	if err := procGetIpForwardTable2.Find(); err != nil {
+		return nil, err
+	}
+	var table unsafe.Pointer
+	if r, _, _ := procGetIpForwardTable2.Call(uintptr(family), uintptr(unsafe.Pointer(&table))); r != 0 {
+		return nil, windows.Errno(r)
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	n := *(*uint32)(table)
+	if n == 0 {
+		return nil, nil
+	}
+	rows := unsafe.Slice((*mibIPForwardRow2)(unsafe.Add(table, mibIPForwardTableRows)), n)
+	return append([]mibIPForwardRow2(nil), rows...), nil
+}
+
+// sockaddrFamily возвращает семейство адреса из SOCKADDR_INET
+func sockaddrFamily(sa *windows.RawSockaddrInet6) string {
+	switch sa.Family {
+	case windows.AF_INET:
+		return familyIPv4
+	case windows.AF_INET6:
+		return familyIPv6
+	}
+	return ""
+}
+
+// sockaddrIP возвращает адрес из SOCKADDR_INET или nil для другого семейства
+func sockaddrIP(sa *windows.RawSockaddrInet6) net.IP {
+	switch sa.Family {
+	case windows.AF_INET:
+		sa4 := (*windows.RawSockaddrInet4)(unsafe.Pointer(sa))
+		return net.IPv4(sa4.Addr[0], sa4.Addr[1], sa4.Addr[2], sa4.Addr[3])
+	case windows.AF_INET6:
+		return append(net.IP(nil), sa.Addr[:]...)
+	}
+	return nil
+}
+
+// adapterKey — индекс интерфейса для семейства адресов: у адаптера
+// индексы IPv4 и IPv6 могут различаться
+type adapterKey struct {
+	family string
+	index  uint32
+}
+
+// adapterInfo — имя и метрика работающего адаптера для семейства
+type adapterInfo struct {
+	name   string
+	metric int
+}
+
+// upAdapters возвращает работающие адаптеры по индексам интерфейсов через
+// GetAdaptersAddresses. Имя — FriendlyName, как у net.Interfaces.
+func upAdapters() (map[adapterKey]adapterInfo, error) {
+	flags := uint32(windows.GAA_FLAG_SKIP_UNICAST | windows.GAA_FLAG_SKIP_ANYCAST |
+		windows.GAA_FLAG_SKIP_MULTICAST | windows.GAA_FLAG_SKIP_DNS_SERVER)
+	size := uint32(15 << 10)
+	var buf []byte
+	for {
+		buf = make([]byte, size)
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, flags, 0, (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])), &size)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, windows.ERROR_BUFFER_OVERFLOW) {
+			return nil, fmt.Errorf("ошибка получения сетевых адаптеров: %v", err)
+		}
+	}
+
+	adapters := make(map[adapterKey]adapterInfo)
+	for aa := (*windows.IpAdapterAddresses)(unsafe.Pointer(&buf[0])); aa != nil; aa = aa.Next {
+		if aa.OperStatus != windows.IfOperStatusUp {
+			continue
+		}
+		name := windows.UTF16PtrToString(aa.FriendlyName)
+		if aa.IfIndex != 0 {
+			adapters[adapterKey{familyIPv4, aa.IfIndex}] = adapterInfo{name, int(aa.Ipv4Metric)}
+		}
+		if aa.Ipv6IfIndex != 0 {
+			adapters[adapterKey{familyIPv6, aa.Ipv6IfIndex}] = adapterInfo{name, int(aa.Ipv6Metric)}
+		}
+	}
+	return adapters, nil
+}
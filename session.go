@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseSessionEnd разбирает длительность сессии сбора статистики и
+// возвращает момент её окончания:
+//
+//	""        — без ограничения (нулевое время)
+//	"1h30m"   — через указанное время
+//	"18:30"   — до ближайшего наступления этого времени суток
+func parseSessionEnd(text string, now time.Time) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "" || text == "0" {
+		return time.Time{}, nil
+	}
+
+	if strings.Contains(text, ":") {
+		clock, err := time.ParseInLocation("15:04", text, now.Location())
+		if err != nil {
+			return time.Time{}, fmt.Errorf("время окончания нужно указать как ЧЧ:ММ: %v", err)
+		}
+		end := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !end.After(now) {
+			end = end.AddDate(0, 0, 1)
+		}
+		return end, nil
+	}
+
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("длительность нужно указать как 30m, 2h или 1h30m: %v", err)
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("длительность должна быть больше нуля")
+	}
+	return now.Add(d), nil
+}
+
+// formatRemaining формирует строку с оставшимся временем сессии
+func formatRemaining(end, now time.Time) string {
+	if end.IsZero() {
+		return "Без ограничения по времени"
+	}
+	left := end.Sub(now).Round(time.Second)
+	if left < 0 {
+		left = 0
+	}
+	h := int(left.Hours())
+	m := int(left.Minutes()) % 60
+	s := int(left.Seconds()) % 60
+	return fmt.Sprintf("Осталось: %02d:%02d:%02d (до %s)", h, m, s, end.Format("02.01 15:04:05"))
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Форматы логов результатов. Текстовый лог (ping_statistics.log и
+// mtr_results.log) предназначен для чтения, jsonl и csv — для разбора
+// программами: одна строка на цикл пинга хоста и на трассировку.
+const (
+	logFormatText  = "text"
+	logFormatJSONL = "jsonl"
+	logFormatCSV   = "csv"
+)
+
+// logFormats — допустимые форматы логов
+var logFormats = []string{logFormatText, logFormatJSONL, logFormatCSV}
+
+// Файлы машиночитаемых логов в каталоге логов
+const (
+	pingJSONLFile = "ping_results.jsonl"
+	mtrJSONLFile  = "mtr_results.jsonl"
+	pingCSVFile   = "ping_results.csv"
+	mtrCSVFile    = "mtr_results.csv"
+)
+
+// sinkFile — машиночитаемый лог name в каталоге логов. Хосты проверяются
+// параллельно, поэтому запись в файл упорядочена его мьютексом, чтобы строка
+// и заголовок CSV не перемешивались; разные файлы пишутся независимо.
+type sinkFile struct {
+	mu   sync.Mutex
+	name string
+}
+
+// logSinks — машиночитаемые логи монитора
+type logSinks struct {
+	pingJSONL sinkFile
+	mtrJSONL  sinkFile
+	pingCSV   sinkFile
+	mtrCSV    sinkFile
+}
+
+// newLogSinks создаёт машиночитаемые логи
+func newLogSinks() *logSinks {
+	return &logSinks{
+		pingJSONL: sinkFile{name: pingJSONLFile},
+		mtrJSONL:  sinkFile{name: mtrJSONLFile},
+		pingCSV:   sinkFile{name: pingCSVFile},
+		mtrCSV:    sinkFile{name: mtrCSVFile},
+	}
+}
+
+// pingRecord — цикл пинга хоста в логе jsonl. RTT в миллисекундах.
+type pingRecord struct {
+	Time     time.Time      `json:"time"`
+	Host     string         `json:"host"`
+	Label    string         `json:"label,omitempty"`
+	Group    string         `json:"group,omitempty"`
+	Prober   string         `json:"prober"`
+	Sent     int            `json:"sent"`
+	Received int            `json:"received"`
+	Loss     float64        `json:"loss_pct"`
+	MinRTT   float64        `json:"min_rtt_ms"`
+	AvgRTT   float64        `json:"avg_rtt_ms"`
+	MaxRTT   float64        `json:"max_rtt_ms"`
+	Jitter   float64        `json:"jitter_ms"`
+	RTTs     []float64      `json:"rtts_ms"` // RTT полученных ответов цикла
+	Failures map[string]int `json:"failures,omitempty"`
+}
+
+// mtrRecord — трассировка в логе jsonl. Hops пуст, если трассировка
+// выполнялась системной утилитой, тогда её вывод — в Output.
+type mtrRecord struct {
+	Time   time.Time      `json:"time"`
+	Target string         `json:"target"`
+	Hops   []mtrHopRecord `json:"hops"`
+	Output string         `json:"output,omitempty"`
+}
+
+// mtrHopRecord — хоп трассировки, времена в миллисекундах
+type mtrHopRecord struct {
+	Hop      int     `json:"hop"`
+	Address  string  `json:"address"`
+	Sent     int     `json:"sent"`
+	Received int     `json:"received"`
+	Loss     float64 `json:"loss_pct"`
+	Last     float64 `json:"last_ms"`
+	Best     float64 `json:"best_ms"`
+	Avg      float64 `json:"avg_ms"`
+	Worst    float64 `json:"worst_ms"`
+	StdDev   float64 `json:"stddev_ms"`
+	Jitter   float64 `json:"jitter_ms"`
+}
+
+// newPingRecord возвращает запись цикла пинга; rtts — RTT ответов цикла, мс
+func newPingRecord(stats *PingStats, rtts []float64) pingRecord {
+	return pingRecord{
+		Time:     stats.LastUpdate,
+		Host:     stats.Host,
+		Label:    stats.Label,
+		Group:    stats.Group,
+		Prober:   stats.Prober,
+		Sent:     stats.Sent,
+		Received: stats.Received,
+		Loss:     stats.PacketLoss,
+		MinRTT:   stats.MinRTT,
+		AvgRTT:   stats.AvgRTT,
+		MaxRTT:   stats.MaxRTT,
+		Jitter:   stats.Jitter,
+		RTTs:     append([]float64{}, rtts...),
+		Failures: stats.Failures,
+	}
+}
+
+// newMTRRecord возвращает запись трассировки до target
+func newMTRRecord(target, output string, hops []WinMTRHop) mtrRecord {
+	record := mtrRecord{Time: time.Now(), Target: target, Hops: []mtrHopRecord{}}
+	for _, hop := range hops {
+		record.Hops = append(record.Hops, mtrHopRecord{
+			Hop:      hop.Hop,
+			Address:  hop.Address,
+			Sent:     hop.Sent,
+			Received: hop.Received,
+			Loss:     hop.Loss,
+			Last:     durationMs(hop.Last),
+			Best:     durationMs(hop.Best),
+			Avg:      durationMs(hop.Avg),
+			Worst:    durationMs(hop.Worst),
+			StdDev:   durationMs(hop.StdDev),
+			Jitter:   durationMs(hop.Jitter),
+		})
+	}
+	if len(hops) == 0 {
+		record.Output = output
+	}
+	return record
+}
+
+// writePingRecord записывает цикл пинга хоста в логи выбранных форматов.
+// Пустой список форматов — только текстовый лог.
+func (s *logSinks) writePingRecord(logDir string, formats []string, stats *PingStats, rtts []float64) error {
+	if logDir == "" {
+		return nil
+	}
+	var errs []string
+	for _, format := range sinkFormats(formats) {
+		var err error
+		switch format {
+		case logFormatText:
+			err = updatePingStats(logDir, stats)
+		case logFormatJSONL:
+			err = s.pingJSONL.appendJSONL(logDir, newPingRecord(stats, rtts))
+		case logFormatCSV:
+			err = s.pingCSV.appendCSV(logDir, pingCSVHeader, [][]string{pingCSVRow(newPingRecord(stats, rtts))})
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// writeMTRRecord записывает трассировку до host в логи выбранных форматов.
+// hops — статистика по хопам встроенной трассировки, nil для системной утилиты.
+func (s *logSinks) writeMTRRecord(logDir string, formats []string, host, output string, hops []WinMTRHop) error {
+	if logDir == "" {
+		return nil
+	}
+	var errs []string
+	for _, format := range sinkFormats(formats) {
+		var err error
+		switch format {
+		case logFormatText:
+			err = updateMTRStats(logDir, host, output)
+		case logFormatJSONL:
+			err = s.mtrJSONL.appendJSONL(logDir, newMTRRecord(host, output, hops))
+		case logFormatCSV:
+			// В CSV попадают только хопы встроенной трассировки
+			if rows := mtrCSVRows(newMTRRecord(host, output, hops)); len(rows) > 0 {
+				err = s.mtrCSV.appendCSV(logDir, mtrCSVHeader, rows)
+			}
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sinkFormats возвращает форматы логов; без настройки — только текст
+func sinkFormats(formats []string) []string {
+	if len(formats) == 0 {
+		return []string{logFormatText}
+	}
+	return formats
+}
+
+// validateLogFormat проверяет название формата лога
+func validateLogFormat(format string) error {
+	for _, f := range logFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("неизвестный формат лога %q (допустимо: %s)", format, strings.Join(logFormats, ", "))
+}
+
+// appendJSONL дописывает v в лог в каталоге logDir отдельной строкой JSON
+func (f *sinkFile) appendJSONL(logDir string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("ошибка при кодировании записи для %s: %v", f.name, err)
+	}
+	data = append(data, '\n')
+
+	path := filepath.Join(logDir, f.name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии файла %s: %v", path, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("ошибка при записи в %s: %v", path, err)
+	}
+	return nil
+}
+
+// appendCSV дописывает строки rows в лог в каталоге logDir; в новый файл
+// сначала записывается заголовок header
+func (f *sinkFile) appendCSV(logDir string, header []string, rows [][]string) error {
+	path := filepath.Join(logDir, f.name)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии файла %s: %v", path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии файла %s: %v", path, err)
+	}
+
+	w := csv.NewWriter(file)
+	if info.Size() == 0 {
+		w.Write(header)
+	}
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("ошибка при записи в %s: %v", path, err)
+	}
+	return nil
+}
+
+// Столбцы CSV-логов. Порядок не меняется, новые столбцы добавляются в конец.
+var (
+	pingCSVHeader = []string{"time", "host", "label", "group", "prober", "sent", "received", "loss_pct", "min_rtt_ms", "avg_rtt_ms", "max_rtt_ms", "jitter_ms", "failures"}
+	mtrCSVHeader  = []string{"time", "target", "hop", "address", "sent", "received", "loss_pct", "last_ms", "best_ms", "avg_ms", "worst_ms", "stddev_ms", "jitter_ms"}
+)
+
+// pingCSVRow возвращает строку CSV-лога для цикла пинга
+func pingCSVRow(r pingRecord) []string {
+	return []string{
+		r.Time.Format(csvTimeFormat),
+		r.Host,
+		r.Label,
+		r.Group,
+		r.Prober,
+		strconv.Itoa(r.Sent),
+		strconv.Itoa(r.Received),
+		csvFloat(r.Loss),
+		csvFloat(r.MinRTT),
+		csvFloat(r.AvgRTT),
+		csvFloat(r.MaxRTT),
+		csvFloat(r.Jitter),
+		csvFailures(r.Failures),
+	}
+}
+
+// mtrCSVRows возвращает строки CSV-лога по хопам трассировки
+func mtrCSVRows(r mtrRecord) [][]string {
+	rows := make([][]string, 0, len(r.Hops))
+	for _, hop := range r.Hops {
+		rows = append(rows, []string{
+			r.Time.Format(csvTimeFormat),
+			r.Target,
+			strconv.Itoa(hop.Hop),
+			hop.Address,
+			strconv.Itoa(hop.Sent),
+			strconv.Itoa(hop.Received),
+			csvFloat(hop.Loss),
+			csvFloat(hop.Last),
+			csvFloat(hop.Best),
+			csvFloat(hop.Avg),
+			csvFloat(hop.Worst),
+			csvFloat(hop.StdDev),
+			csvFloat(hop.Jitter),
+		})
+	}
+	return rows
+}
+
+// csvTimeFormat — время в CSV: RFC 3339 с миллисекундами
+const csvTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// csvFloat форматирует число для CSV: точка как разделитель дробной части
+// независимо от языка системы, три знака после точки
+func csvFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
+
+// csvFailures записывает причины потерь как "refused=1;timeout=2"
+// в алфавитном порядке
+func csvFailures(failures map[string]int) string {
+	reasons := make([]string, 0, len(failures))
+	for reason := range failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	parts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		parts = append(parts, reason+"="+strconv.Itoa(failures[reason]))
+	}
+	return strings.Join(parts, ";")
+}
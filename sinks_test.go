@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newSinkTestDir создаёт каталог логов с текстовыми логами
+func newSinkTestDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := ensureLogDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func readSinkFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWritePingRecord(t *testing.T) {
+	dir := newSinkTestDir(t)
+	stats := &PingStats{
+		Host: "https://example.com/a,b", Label: `Сайт "А", резерв`, Group: "Веб", Prober: "http",
+		Sent: 4, Received: 3, PacketLoss: 25, MinRTT: 10, AvgRTT: 15.5, MaxRTT: 20, Jitter: 2.25,
+		LastUpdate: time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC),
+		Failures:   map[string]int{failTimeout: 1},
+	}
+	sinks := newLogSinks()
+	if err := sinks.writePingRecord(dir, logFormats, stats, []float64{10, 16.5, 20}); err != nil {
+		t.Fatal(err)
+	}
+
+	if text := readSinkFile(t, dir, "ping_statistics.log"); !strings.Contains(text, "Хост: "+stats.DisplayName()) || !strings.Contains(text, "Среднее RTT: 15.50 мс") {
+		t.Errorf("текстовый лог:\n%s", text)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(readSinkFile(t, dir, pingJSONLFile), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("строк jsonl: %d", len(lines))
+	}
+	var record pingRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Host != stats.Host || record.Label != stats.Label || record.Loss != 25 || len(record.RTTs) != 3 || record.Failures[failTimeout] != 1 {
+		t.Errorf("запись jsonl: %+v", record)
+	}
+
+	data := readSinkFile(t, dir, pingCSVFile)
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"2026-05-01T18:00:00.000Z", stats.Host, stats.Label, "Веб", "http", "4", "3", "25.000", "10.000", "15.500", "20.000", "2.250", "timeout=1"}
+	if len(records) != 2 || strings.Join(records[0], ",") != strings.Join(pingCSVHeader, ",") || fmt.Sprint(records[1]) != fmt.Sprint(want) {
+		t.Errorf("CSV-лог:\n%s", data)
+	}
+	// Поля с запятыми и кавычками взяты в кавычки
+	if !strings.Contains(data, `,"https://example.com/a,b","Сайт ""А"", резерв",`) {
+		t.Errorf("поля CSV без кавычек:\n%s", data)
+	}
+}
+
+func TestWritePingRecordTextOnly(t *testing.T) {
+	dir := newSinkTestDir(t)
+	stats := &PingStats{Host: "8.8.8.8", Sent: 4, Received: 4, LastUpdate: time.Now()}
+	if err := newLogSinks().writePingRecord(dir, nil, stats, nil); err != nil {
+		t.Fatal(err)
+	}
+	if text := readSinkFile(t, dir, "ping_statistics.log"); !strings.Contains(text, "Хост: 8.8.8.8") {
+		t.Errorf("текстовый лог:\n%s", text)
+	}
+	for _, name := range []string{pingJSONLFile, pingCSVFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s создан без настройки: %v", name, err)
+		}
+	}
+}
+
+func TestWriteMTRRecord(t *testing.T) {
+	dir := newSinkTestDir(t)
+	hops := []WinMTRHop{
+		{Hop: 1, Address: "192.168.1.1", Sent: 5, Received: 5, Last: ms(1), Best: ms(0.5), Avg: ms(1), Worst: ms(2)},
+		{Hop: 2, Address: "*", Sent: 5, Received: 0, Loss: 100},
+	}
+	sinks := newLogSinks()
+	if err := sinks.writeMTRRecord(dir, logFormats, "example.com", "отчёт", hops); err != nil {
+		t.Fatal(err)
+	}
+
+	if text := readSinkFile(t, dir, "mtr_results.log"); !strings.Contains(text, "Результаты MTR до example.com:\nотчёт") {
+		t.Errorf("текстовый лог:\n%s", text)
+	}
+
+	var record mtrRecord
+	if err := json.Unmarshal([]byte(readSinkFile(t, dir, mtrJSONLFile)), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Target != "example.com" || len(record.Hops) != 2 || record.Hops[0].Best != 0.5 || record.Output != "" {
+		t.Errorf("запись jsonl: %+v", record)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(readSinkFile(t, dir, mtrCSVFile))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][3] != "192.168.1.1" || records[1][8] != "0.500" || records[2][6] != "100.000" {
+		t.Errorf("CSV-лог: %q", records)
+	}
+
+	// Трассировка системной утилитой: вывод в jsonl, в CSV строк нет
+	if err := sinks.writeMTRRecord(dir, logFormats, "example.com", "вывод mtr", nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(readSinkFile(t, dir, mtrJSONLFile), "\n"), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"output":"вывод mtr"`) || !strings.Contains(lines[1], `"hops":[]`) {
+		t.Errorf("jsonl:\n%s", strings.Join(lines, "\n"))
+	}
+	if records, _ := csv.NewReader(strings.NewReader(readSinkFile(t, dir, mtrCSVFile))).ReadAll(); len(records) != 3 {
+		t.Errorf("строк CSV: %d", len(records))
+	}
+}
+
+// Параллельные циклы хостов не перемешивают строки и пишут заголовок один раз
+func TestWritePingRecordConcurrent(t *testing.T) {
+	dir := newSinkTestDir(t)
+	sinks := newLogSinks()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats := &PingStats{Host: fmt.Sprintf("10.0.0.%d", i), Sent: 4, Received: 4, LastUpdate: time.Now()}
+			if err := sinks.writePingRecord(dir, []string{logFormatJSONL, logFormatCSV}, stats, []float64{1, 2, 3, 4}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := csv.NewReader(strings.NewReader(readSinkFile(t, dir, pingCSVFile))).ReadAll()
+	if err != nil || len(records) != 51 || records[0][0] != "time" {
+		t.Errorf("CSV-лог: %d строк, %v", len(records), err)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(readSinkFile(t, dir, pingJSONLFile)))
+	n := 0
+	for ; scanner.Scan(); n++ {
+		var record pingRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("строка %d: %v", n+1, err)
+		}
+	}
+	if n != 50 {
+		t.Errorf("строк jsonl: %d", n)
+	}
+}
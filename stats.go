@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type PingStats struct {
+	Host       string
+	Label      string // Подпись и группа хоста из конфигурации
+	Group      string
+	Prober     string // Способ проверки — схема записи хоста: icmp, tcp, http, dns, ...
+	MinRTT     float64
+	MaxRTT     float64
+	AvgRTT     float64
+	PacketLoss float64
+	LastUpdate time.Time
+	Sent       int // Отправлено и получено в последнем цикле
+	Received   int
+	Failures   map[string]int // Причины потерь в последнем цикле (failTimeout, failRefused, ...)
+
+	// RTT полученных пакетов за последние MonitorOptions.Window измерений и
+	// рассчитанные по ним показатели, мс
+	Samples []float64
+	P50     float64
+	P90     float64
+	P95     float64
+	P99     float64
+	StdDev  float64
+	Jitter  float64
+
+	// Накопленные показатели с начала сессии
+	TotalSent     int
+	TotalReceived int
+	TotalLoss     float64 // %
+	LifetimeMin   float64
+	LifetimeMax   float64
+	RunningMean   float64
+	LastSuccess   time.Time // Время последнего цикла с ответами
+	TotalFailures map[string]int
+
+	// Гистограмма RTT за сессию: RTTBuckets[i] — число ответов с RTT не
+	// больше rttBuckets[i], RTTCount и RTTSum — число и сумма RTT всех ответов, мс
+	RTTBuckets []int
+	RTTCount   int
+	RTTSum     float64
+
+	HTTP *HTTPStats // Для http(s): этапы запроса в последнем цикле, nil для других способов
+	DNS  *DNSStats  // Для проверок DNS: последний ответ сервера, nil для других способов
+	TLS  *TLSStats  // Для tls и https: сертификат из последнего цикла, nil для других способов
+}
+
+// TLSStats — параметры TLS-соединения и сертификат сервера
+type TLSStats struct {
+	Handshake float64 // Время рукопожатия, мс
+	Version   string
+	Cipher    string
+	Chain     []TLSCert // Цепочка сертификатов, начиная с сертификата сервера
+	NotAfter  time.Time // Окончание срока действия цепочки
+	DaysLeft  int
+	Warning   bool   // До истечения меньше порога probe.cert_warn_days
+	Error     string // Ошибка рукопожатия или проверки сертификата
+}
+
+// TLSCert — сертификат из цепочки
+type TLSCert struct {
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+}
+
+// HTTPStats — этапы HTTP(S)-запроса, усреднённые по ответам цикла, мс
+type HTTPStats struct {
+	DNS        float64
+	Connect    float64
+	TLS        float64
+	TTFB       float64 // От отправки запроса до первого байта ответа
+	Total      float64
+	StatusCode int    // Код последнего ответа, 0 — ответов не было
+	Error      string // Последняя ошибка или непройденная проверка в цикле
+}
+
+// rttBuckets — верхние границы корзин гистограммы RTT, мс
+var rttBuckets = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500}
+
+// DisplayName возвращает подпись хоста для таблицы и логов
+func (s *PingStats) DisplayName() string {
+	if s.Label != "" {
+		return fmt.Sprintf("%s (%s)", s.Label, s.Host)
+	}
+	return s.Host
+}
+
+// mergeSamples добавляет новые RTT к окну предыдущих и обрезает его
+// до window последних значений
+func mergeSamples(prev, next []float64, window int) []float64 {
+	samples := make([]float64, 0, len(prev)+len(next))
+	samples = append(samples, prev...)
+	samples = append(samples, next...)
+	if window > 0 && len(samples) > window {
+		samples = samples[len(samples)-window:]
+	}
+	return samples
+}
+
+// updateWindowStats пересчитывает перцентили, СКО и джиттер по окну RTT
+func updateWindowStats(stats *PingStats) {
+	stats.P50, stats.P90, stats.P95, stats.P99 = 0, 0, 0, 0
+	stats.StdDev, stats.Jitter = 0, 0
+	if len(stats.Samples) == 0 {
+		return
+	}
+
+	sorted := make([]float64, len(stats.Samples))
+	copy(sorted, stats.Samples)
+	sort.Float64s(sorted)
+	stats.P50 = percentile(sorted, 50)
+	stats.P90 = percentile(sorted, 90)
+	stats.P95 = percentile(sorted, 95)
+	stats.P99 = percentile(sorted, 99)
+	stats.StdDev = stdDev(stats.Samples)
+	stats.Jitter = rfc3550Jitter(stats.Samples)
+}
+
+// percentile возвращает p-й перцентиль отсортированной выборки
+// методом ближайшего ранга
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// stdDev возвращает стандартное отклонение выборки
+func stdDev(samples []float64) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, v := range samples {
+		mean += v
+	}
+	mean /= float64(len(samples))
+
+	var sum float64
+	for _, v := range samples {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+// rfc3550Jitter считает межпакетный джиттер по RFC 3550 (раздел 6.4.1):
+// J(i) = J(i-1) + (|D(i-1,i)| - J(i-1)) / 16, где D — разница соседних RTT
+func rfc3550Jitter(samples []float64) float64 {
+	var jitter float64
+	for i := 1; i < len(samples); i++ {
+		d := math.Abs(samples[i] - samples[i-1])
+		jitter += (d - jitter) / 16
+	}
+	return jitter
+}
+
+// mergeSession добавляет показатели нового цикла к накопленным за сессию.
+// prev может быть nil для первого цикла, samples — RTT ответов этого цикла.
+func mergeSession(prev, stats *PingStats, samples []float64) {
+	stats.RTTBuckets = make([]int, len(rttBuckets))
+	if prev != nil {
+		copy(stats.RTTBuckets, prev.RTTBuckets)
+		stats.RTTCount = prev.RTTCount
+		stats.RTTSum = prev.RTTSum
+		stats.LastSuccess = prev.LastSuccess
+	}
+	for _, rtt := range samples {
+		for i, bound := range rttBuckets {
+			if rtt <= bound {
+				stats.RTTBuckets[i]++
+			}
+		}
+		stats.RTTCount++
+		stats.RTTSum += rtt
+	}
+	if stats.Received > 0 {
+		stats.LastSuccess = stats.LastUpdate
+	}
+
+	stats.TotalSent = stats.Sent
+	stats.TotalReceived = stats.Received
+	stats.LifetimeMin = stats.MinRTT
+	stats.LifetimeMax = stats.MaxRTT
+	stats.RunningMean = stats.AvgRTT
+	if prev != nil {
+		stats.TotalSent += prev.TotalSent
+		stats.TotalReceived += prev.TotalReceived
+		if prev.TotalReceived > 0 {
+			if stats.Received == 0 || prev.LifetimeMin < stats.LifetimeMin {
+				stats.LifetimeMin = prev.LifetimeMin
+			}
+			if prev.LifetimeMax > stats.LifetimeMax {
+				stats.LifetimeMax = prev.LifetimeMax
+			}
+			// Среднее по всем полученным пакетам сессии
+			sum := prev.RunningMean*float64(prev.TotalReceived) + stats.AvgRTT*float64(stats.Received)
+			stats.RunningMean = sum / float64(stats.TotalReceived)
+		}
+	}
+
+	stats.TotalFailures = make(map[string]int, len(stats.Failures))
+	if prev != nil {
+		for reason, n := range prev.TotalFailures {
+			stats.TotalFailures[reason] = n
+		}
+	}
+	for reason, n := range stats.Failures {
+		stats.TotalFailures[reason] += n
+	}
+
+	stats.TotalLoss = 0
+	if stats.TotalSent > 0 {
+		stats.TotalLoss = float64(stats.TotalSent-stats.TotalReceived) * 100 / float64(stats.TotalSent)
+	}
+}
+
+// formatSessionStats формирует блок накопленной за сессию статистики
+func formatSessionStats(stats *PingStats) string {
+	text := "  За сессию:\n"
+	text += fmt.Sprintf("    Отправлено/получено: %d/%d\n", stats.TotalSent, stats.TotalReceived)
+	text += fmt.Sprintf("    Потери пакетов: %.1f%%\n", stats.TotalLoss)
+	text += fmt.Sprintf("    Минимальное RTT: %.2f мс\n", stats.LifetimeMin)
+	text += fmt.Sprintf("    Среднее RTT: %.2f мс\n", stats.RunningMean)
+	text += fmt.Sprintf("    Максимальное RTT: %.2f мс\n", stats.LifetimeMax)
+	if len(stats.TotalFailures) > 0 {
+		text += fmt.Sprintf("    Причины потерь: %s\n", formatFailures(stats.TotalFailures))
+	}
+	return text
+}
+
+// DNSStats — сведения о запросах к DNS-серверу в последнем цикле
+type DNSStats struct {
+	Server    string // Адрес сервера с портом
+	Transport string // udp, tcp или tls
+	Name      string
+	Type      string
+	Rcode     string // Код последнего ответа: NOERROR, NXDOMAIN, SERVFAIL, ...
+	Answers   int    // Записей в последнем ответе
+	Error     string // Последняя ошибка запроса в цикле
+}
+
+// formatDNSStats формирует блок со сведениями о запросах к DNS-серверу
+func formatDNSStats(d *DNSStats) string {
+	text := fmt.Sprintf("  DNS (%s %s, %s):\n", d.Type, d.Name, d.Transport)
+	if d.Rcode != "" {
+		text += fmt.Sprintf("    Код ответа: %s, записей: %d\n", d.Rcode, d.Answers)
+	}
+	if d.Error != "" {
+		text += fmt.Sprintf("    Ошибка: %s\n", d.Error)
+	}
+	return text
+}
+
+// formatTLSStats формирует блок со сведениями о TLS-соединении и сертификате
+func formatTLSStats(t *TLSStats) string {
+	text := "  TLS:\n"
+	if t.Version != "" {
+		text += fmt.Sprintf("    Рукопожатие: %.2f мс, %s, %s\n", t.Handshake, t.Version, t.Cipher)
+	}
+	if len(t.Chain) > 0 {
+		text += fmt.Sprintf("    Сертификат действует до %s (осталось дней: %d)\n", t.NotAfter.Format("2006/01/02"), t.DaysLeft)
+		if t.Warning {
+			text += "    ВНИМАНИЕ: срок действия сертификата скоро истекает\n"
+		}
+		for i, cert := range t.Chain {
+			text += fmt.Sprintf("    %d. %s\n       выдан: %s\n", i+1, cert.Subject, cert.Issuer)
+		}
+	}
+	if t.Error != "" {
+		text += fmt.Sprintf("    Ошибка: %s\n", t.Error)
+	}
+	return text
+}
+
+// formatHTTPStats формирует блок с этапами HTTP(S)-запроса
+func formatHTTPStats(h *HTTPStats) string {
+	text := "  HTTP:\n"
+	if h.StatusCode != 0 {
+		text += fmt.Sprintf("    Код ответа: %d\n", h.StatusCode)
+		text += fmt.Sprintf("    DNS/соединение/TLS/первый байт/всего: %.2f/%.2f/%.2f/%.2f/%.2f мс\n",
+			h.DNS, h.Connect, h.TLS, h.TTFB, h.Total)
+	}
+	if h.Error != "" {
+		text += fmt.Sprintf("    Ошибка: %s\n", h.Error)
+	}
+	return text
+}
+
+// formatFailures формирует строку с числом потерь по причинам,
+// например "таймаут: 3, отказ в соединении: 1"
+func formatFailures(failures map[string]int) string {
+	reasons := make([]string, 0, len(failures))
+	for reason := range failures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	parts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		parts = append(parts, fmt.Sprintf("%s: %d", failReasonName(reason), failures[reason]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	// Примеры метода ближайшего ранга из литературы
+	small := []float64{15, 20, 35, 40, 50}
+	ten := []float64{3, 6, 7, 8, 8, 10, 13, 15, 16, 20}
+	tests := []struct {
+		name   string
+		sorted []float64
+		p      float64
+		want   float64
+	}{
+		{"пустая выборка", nil, 50, 0},
+		{"одно значение", []float64{7}, 99, 7},
+		{"p0 — минимум", small, 0, 15},
+		{"p5", small, 5, 15},
+		{"p30", small, 30, 20},
+		{"p40", small, 40, 20},
+		{"p50", small, 50, 35},
+		{"p100", small, 100, 50},
+		{"p25 из 10", ten, 25, 7},
+		{"p50 из 10", ten, 50, 8},
+		{"p75 из 10", ten, 75, 15},
+		{"p90 из 10", ten, 90, 16},
+		{"p95 из 10", ten, 95, 20},
+		{"p99 из 10", ten, 99, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := percentile(tt.sorted, tt.p); got != tt.want {
+				t.Errorf("percentile(%v, %v) = %v, ожидалось %v", tt.sorted, tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		want    float64
+	}{
+		{"пустая выборка", nil, 0},
+		{"одно значение", []float64{5}, 0},
+		{"одинаковые значения", []float64{3, 3, 3}, 0},
+		{"СКО генеральной совокупности", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stdDev(tt.samples); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("stdDev(%v) = %v, ожидалось %v", tt.samples, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRFC3550Jitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		want    float64
+	}{
+		{"пустая выборка", nil, 0},
+		{"одно значение", []float64{10}, 0},
+		{"постоянное RTT", []float64{10, 10, 10, 10}, 0},
+		// J = 2/16; J += (1 - J)/16; J += (4 - J)/16
+		{"меняющееся RTT", []float64{10, 12, 11, 15}, 0.41845703125},
+		// Знак разницы не важен: те же |D| при обратных знаках
+		{"обратные знаки", []float64{10, 8, 9, 5}, 0.41845703125},
+		// Один скачок на 16 мс даёт 1 мс
+		{"один скачок", []float64{20, 36}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rfc3550Jitter(tt.samples); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("rfc3550Jitter(%v) = %v, ожидалось %v", tt.samples, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeSession(t *testing.T) {
+	t1 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	t2 := t1.Add(10 * time.Second)
+	t3 := t2.Add(10 * time.Second)
+
+	// Первый цикл: 4 из 4, RTT 2–8 мс
+	first := &PingStats{
+		Host: "8.8.8.8", Sent: 4, Received: 4, MinRTT: 2, AvgRTT: 5, MaxRTT: 8, LastUpdate: t1,
+	}
+	mergeSession(nil, first, []float64{2, 4, 6, 8})
+	if first.TotalSent != 4 || first.TotalReceived != 4 || first.TotalLoss != 0 {
+		t.Fatalf("первый цикл: %d/%d, потери %.1f%%", first.TotalSent, first.TotalReceived, first.TotalLoss)
+	}
+	if first.LifetimeMin != 2 || first.LifetimeMax != 8 || first.RunningMean != 5 {
+		t.Errorf("первый цикл: мин/сред/макс %v/%v/%v", first.LifetimeMin, first.RunningMean, first.LifetimeMax)
+	}
+	if !first.LastSuccess.Equal(t1) {
+		t.Errorf("первый цикл: последний ответ %v, ожидалось %v", first.LastSuccess, t1)
+	}
+
+	// Второй цикл: все пакеты потеряны — мин/макс/среднее не меняются
+	second := &PingStats{
+		Host: "8.8.8.8", Sent: 4, LastUpdate: t2, Failures: map[string]int{failTimeout: 4},
+	}
+	mergeSession(first, second, nil)
+	if second.TotalSent != 8 || second.TotalReceived != 4 || second.TotalLoss != 50 {
+		t.Errorf("второй цикл: %d/%d, потери %.1f%%", second.TotalSent, second.TotalReceived, second.TotalLoss)
+	}
+	if second.LifetimeMin != 2 || second.LifetimeMax != 8 || second.RunningMean != 5 {
+		t.Errorf("второй цикл: мин/сред/макс %v/%v/%v", second.LifetimeMin, second.RunningMean, second.LifetimeMax)
+	}
+	if !second.LastSuccess.Equal(t1) {
+		t.Errorf("второй цикл: последний ответ %v, ожидалось %v", second.LastSuccess, t1)
+	}
+
+	// Третий цикл: 2 из 4, RTT 1 и 11 мс — среднее взвешивается по числу ответов
+	third := &PingStats{
+		Host: "8.8.8.8", Sent: 4, Received: 2, MinRTT: 1, AvgRTT: 6, MaxRTT: 11, LastUpdate: t3,
+		Failures: map[string]int{failTimeout: 2},
+	}
+	mergeSession(second, third, []float64{1, 11})
+	if third.TotalSent != 12 || third.TotalReceived != 6 || third.TotalLoss != 50 {
+		t.Errorf("третий цикл: %d/%d, потери %.1f%%", third.TotalSent, third.TotalReceived, third.TotalLoss)
+	}
+	if third.LifetimeMin != 1 || third.LifetimeMax != 11 {
+		t.Errorf("третий цикл: мин/макс %v/%v", third.LifetimeMin, third.LifetimeMax)
+	}
+	if want := (5.0*4 + 6*2) / 6; math.Abs(third.RunningMean-want) > 1e-9 {
+		t.Errorf("третий цикл: среднее %v, ожидалось %v", third.RunningMean, want)
+	}
+	if third.TotalFailures[failTimeout] != 6 {
+		t.Errorf("третий цикл: таймаутов %d, ожидалось 6", third.TotalFailures[failTimeout])
+	}
+	if !third.LastSuccess.Equal(t3) {
+		t.Errorf("третий цикл: последний ответ %v, ожидалось %v", third.LastSuccess, t3)
+	}
+
+	// Гистограмма накопительная: в корзину ≤ 10 мс попадают 2, 4, 6, 8 и 1
+	if third.RTTCount != 6 || third.RTTSum != 32 {
+		t.Errorf("гистограмма: число %d, сумма %v", third.RTTCount, third.RTTSum)
+	}
+	for i, bound := range rttBuckets {
+		want := 0
+		for _, rtt := range []float64{2, 4, 6, 8, 1, 11} {
+			if rtt <= bound {
+				want++
+			}
+		}
+		if third.RTTBuckets[i] != want {
+			t.Errorf("корзина ≤ %v: %d, ожидалось %d", bound, third.RTTBuckets[i], want)
+		}
+	}
+
+	// Накопленные причины не должны меняться через новый цикл
+	if second.TotalFailures[failTimeout] != 4 {
+		t.Errorf("второй цикл изменён: таймаутов %d", second.TotalFailures[failTimeout])
+	}
+}
+
+func TestMergeSamples(t *testing.T) {
+	got := mergeSamples([]float64{1, 2, 3}, []float64{4, 5}, 4)
+	want := []float64{2, 3, 4, 5}
+	if len(got) != len(want) {
+		t.Fatalf("mergeSamples = %v, ожидалось %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("mergeSamples = %v, ожидалось %v", got, want)
+		}
+	}
+}
@@ -4,15 +4,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"runtime"
 	"time"
 )
 
-// Функция для проверки и создания каталога логов
-func ensureLogDir() error {
-	logDir := "stats_and_graphs"
-	if runtime.GOOS == "windows" {
-		logDir = filepath.Join(".", logDir)
+// Функция для проверки и создания каталога логов. Пустой logDir — логи
+// в файлы не пишутся, и все функции записи ничего не делают.
+func ensureLogDir(logDir string) error {
+	if logDir == "" {
+		return nil
 	}
 
 	// Создаем каталог, если его нет
@@ -28,19 +27,20 @@ func ensureLogDir() error {
 	}
 
 	for _, file := range files {
-		if _, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644); err != nil {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
 			return fmt.Errorf("ошибка при создании файла %s: %v", file, err)
 		}
+		f.Close()
 	}
 
 	return nil
 }
 
 // Функция для обновления статистики пинга в файле логов
-func updatePingStats(host string, stats *PingStats) error {
-	logDir := "stats_and_graphs"
-	if runtime.GOOS == "windows" {
-		logDir = filepath.Join(".", logDir)
+func updatePingStats(logDir string, stats *PingStats) error {
+	if logDir == "" {
+		return nil
 	}
 
 	// Открываем файл для добавления
@@ -54,14 +54,27 @@ func updatePingStats(host string, stats *PingStats) error {
 	// Записываем статистику
 	timestamp := time.Now().Format("2006/01/02 15:04:05")
 	statsStr := fmt.Sprintf(
-		"%s Хост: %s\n  Минимальное RTT: %.2f мс\n  Среднее RTT: %.2f мс\n  Максимальное RTT: %.2f мс\n  Потери пакетов: %.1f%%\n\n",
+		"%s Хост: %s\n  Минимальное RTT: %.2f мс\n  Среднее RTT: %.2f мс\n  Максимальное RTT: %.2f мс\n  Потери пакетов: %.1f%%\n",
 		timestamp,
-		host,
+		stats.DisplayName(),
 		stats.MinRTT,
 		stats.AvgRTT,
 		stats.MaxRTT,
 		stats.PacketLoss,
 	)
+	if len(stats.Failures) > 0 {
+		statsStr += fmt.Sprintf("  Причины потерь: %s\n", formatFailures(stats.Failures))
+	}
+	if stats.HTTP != nil {
+		statsStr += formatHTTPStats(stats.HTTP)
+	}
+	if stats.DNS != nil {
+		statsStr += formatDNSStats(stats.DNS)
+	}
+	if stats.TLS != nil {
+		statsStr += formatTLSStats(stats.TLS)
+	}
+	statsStr += "\n"
 
 	if _, err := file.WriteString(statsStr); err != nil {
 		return fmt.Errorf("ошибка при записи статистики пинга: %v", err)
@@ -71,10 +84,9 @@ func updatePingStats(host string, stats *PingStats) error {
 }
 
 // Функция для обновления статистики MTR в файле логов
-func updateMTRStats(host string, output string) error {
-	logDir := "stats_and_graphs"
-	if runtime.GOOS == "windows" {
-		logDir = filepath.Join(".", logDir)
+func updateMTRStats(logDir, host, output string) error {
+	if logDir == "" {
+		return nil
 	}
 
 	// Открываем файл для добавления
@@ -95,3 +107,61 @@ func updateMTRStats(host string, output string) error {
 
 	return nil
 }
+
+// Функция для записи итоговой статистики в каталог логов
+func updateLogDir(logDir string, snapshot []PingStats) error {
+	if logDir == "" {
+		return nil
+	}
+
+	// Создаем каталог, если его нет
+	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
+		return fmt.Errorf("ошибка при создании каталога для логов: %v", err)
+	}
+
+	// Создаем или обновляем файл с итоговой статистикой
+	statsFile := filepath.Join(logDir, "final_statistics.log")
+	file, err := os.OpenFile(statsFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии файла статистики: %v", err)
+	}
+	defer file.Close()
+
+	// Записываем текущую статистику
+	file.WriteString("Итоговая статистика пинга:\n\n")
+	for i := range snapshot {
+		stats := &snapshot[i]
+		file.WriteString(fmt.Sprintf("Хост: %s\n", stats.DisplayName()))
+		if stats.Group != "" {
+			file.WriteString(fmt.Sprintf("  Группа: %s\n", stats.Group))
+		}
+		file.WriteString("  Текущий цикл:\n")
+		file.WriteString(fmt.Sprintf("  Минимальное RTT: %.2f мс\n", stats.MinRTT))
+		file.WriteString(fmt.Sprintf("  Среднее RTT: %.2f мс\n", stats.AvgRTT))
+		file.WriteString(fmt.Sprintf("  Максимальное RTT: %.2f мс\n", stats.MaxRTT))
+		file.WriteString(fmt.Sprintf("  Потери пакетов: %.1f%%\n", stats.PacketLoss))
+		file.WriteString(fmt.Sprintf("  Перцентили RTT (p50/p90/p95/p99): %.2f/%.2f/%.2f/%.2f мс\n", stats.P50, stats.P90, stats.P95, stats.P99))
+		file.WriteString(fmt.Sprintf("  Стандартное отклонение: %.2f мс\n", stats.StdDev))
+		file.WriteString(fmt.Sprintf("  Джиттер (RFC 3550): %.2f мс\n", stats.Jitter))
+		file.WriteString(fmt.Sprintf("  Измерений в окне: %d\n", len(stats.Samples)))
+		if stats.HTTP != nil {
+			file.WriteString(formatHTTPStats(stats.HTTP))
+		}
+		if stats.DNS != nil {
+			file.WriteString(formatDNSStats(stats.DNS))
+		}
+		if stats.TLS != nil {
+			file.WriteString(formatTLSStats(stats.TLS))
+		}
+		file.WriteString(formatSessionStats(stats))
+		file.WriteString(fmt.Sprintf("  Последнее обновление: %s\n\n", stats.LastUpdate.Format("2006/01/02 15:04:05")))
+	}
+
+	// Сравнение DNS-серверов, если они проверялись
+	if len(dnsComparison(snapshot)) > 0 {
+		file.WriteString("Сравнение DNS-серверов:\n\n")
+		file.WriteString(formatDNSComparison(snapshot))
+	}
+
+	return nil
+}